@@ -3,12 +3,15 @@ package ast
 import (
 	"bytes"
 	"interpreter/token"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
 }
 
 type Statement interface {
@@ -24,6 +27,7 @@ type Expression interface {
 // program (root node)
 type Program struct {
 	Statements []Statement
+	Comments   []token.Token
 }
 
 func (program *Program) TokenLiteral() string {
@@ -34,6 +38,13 @@ func (program *Program) TokenLiteral() string {
 	}
 }
 
+func (program *Program) Pos() token.Position {
+	if len(program.Statements) > 0 {
+		return program.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (program *Program) String() string {
 	var out bytes.Buffer
 	for _, statement := range program.Statements {
@@ -51,6 +62,7 @@ type LetStatement struct {
 
 func (LetStatement *LetStatement) statementNode()       {}
 func (letStatement *LetStatement) TokenLiteral() string { return letStatement.Token.Literal }
+func (letStatement *LetStatement) Pos() token.Position  { return letStatement.Token.Position }
 func (letStatement *LetStatement) String() string {
 	var buffer bytes.Buffer
 
@@ -66,6 +78,38 @@ func (letStatement *LetStatement) String() string {
 	return buffer.String()
 }
 
+// export
+type ExportStatement struct {
+	Token     token.Token
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Position }
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+// import { a, b } from "path"
+type ImportStatement struct {
+	Token token.Token
+	Names []*Identifier
+	Path  *StringLiteral
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position  { return is.Token.Position }
+func (is *ImportStatement) String() string {
+	names := []string{}
+	for _, name := range is.Names {
+		names = append(names, name.String())
+	}
+
+	return is.TokenLiteral() + " { " + strings.Join(names, ", ") + " } from " + strconv.Quote(is.Path.Value) + ";"
+}
+
 // identifier
 type Identifier struct {
 	Token token.Token
@@ -74,6 +118,7 @@ type Identifier struct {
 
 func (identifier *Identifier) expressionNode()      {}
 func (identifier *Identifier) TokenLiteral() string { return identifier.Token.Literal }
+func (identifier *Identifier) Pos() token.Position  { return identifier.Token.Position }
 func (identifier *Identifier) String() string       { return identifier.Value }
 
 // return
@@ -84,6 +129,7 @@ type ReturnStatement struct {
 
 func (returnStatement *ReturnStatement) statementNode()       {}
 func (returnStatement *ReturnStatement) TokenLiteral() string { return returnStatement.Token.Literal }
+func (returnStatement *ReturnStatement) Pos() token.Position  { return returnStatement.Token.Position }
 func (returnStatement *ReturnStatement) String() string {
 	var buffer bytes.Buffer
 
@@ -107,6 +153,9 @@ func (expressionStatement *ExpressionStatement) statementNode() {}
 func (expressionStatement *ExpressionStatement) TokenLiteral() string {
 	return expressionStatement.Token.Literal
 }
+func (expressionStatement *ExpressionStatement) Pos() token.Position {
+	return expressionStatement.Token.Position
+}
 func (expressionStatement *ExpressionStatement) String() string {
 	if expressionStatement.Expression != nil {
 		return expressionStatement.Expression.String()
@@ -118,12 +167,25 @@ func (expressionStatement *ExpressionStatement) String() string {
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // set instead of Value when the literal needs more than 64 bits
 }
 
 func (integerLiteral IntegerLiteral) expressionNode()      {}
 func (integerLiteral IntegerLiteral) TokenLiteral() string { return integerLiteral.Token.Literal }
+func (integerLiteral IntegerLiteral) Pos() token.Position  { return integerLiteral.Token.Position }
 func (integerLiteral IntegerLiteral) String() string       { return integerLiteral.Token.Literal }
 
+// float
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (floatLiteral *FloatLiteral) expressionNode()      {}
+func (floatLiteral *FloatLiteral) TokenLiteral() string { return floatLiteral.Token.Literal }
+func (floatLiteral *FloatLiteral) Pos() token.Position  { return floatLiteral.Token.Position }
+func (floatLiteral *FloatLiteral) String() string       { return floatLiteral.Token.Literal }
+
 // prefix expression
 type PrefixExpression struct {
 	Token    token.Token
@@ -133,6 +195,7 @@ type PrefixExpression struct {
 
 func (prefixExpression PrefixExpression) expressionNode()      {}
 func (prefixExpression PrefixExpression) TokenLiteral() string { return prefixExpression.Token.Literal }
+func (prefixExpression PrefixExpression) Pos() token.Position  { return prefixExpression.Token.Position }
 func (prefixExpression PrefixExpression) String() string {
 	var buffer bytes.Buffer
 
@@ -154,6 +217,7 @@ type InfixExpression struct {
 
 func (infixExpression InfixExpression) expressionNode()      {}
 func (infixExpression InfixExpression) TokenLiteral() string { return infixExpression.Token.Literal }
+func (infixExpression InfixExpression) Pos() token.Position  { return infixExpression.Token.Position }
 func (infixExpression InfixExpression) String() string {
 	var buffer bytes.Buffer
 
@@ -166,6 +230,29 @@ func (infixExpression InfixExpression) String() string {
 	return buffer.String()
 }
 
+// assignment, plain (x = 1) or compound (x += 1)
+type AssignExpression struct {
+	Token    token.Token
+	Target   Expression // *Identifier or *IndexExpression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Token.Position }
+func (ae *AssignExpression) String() string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString("(")
+	buffer.WriteString(ae.Target.String())
+	buffer.WriteString(" " + ae.Operator + " ")
+	buffer.WriteString(ae.Value.String())
+	buffer.WriteString(")")
+
+	return buffer.String()
+}
+
 // Boolean
 type Boolean struct {
 	Token token.Token
@@ -174,6 +261,7 @@ type Boolean struct {
 
 func (boolean *Boolean) expressionNode()      {}
 func (boolean *Boolean) TokenLiteral() string { return boolean.Token.Literal }
+func (boolean *Boolean) Pos() token.Position  { return boolean.Token.Position }
 func (boolean *Boolean) String() string       { return boolean.Token.Literal }
 
 // If
@@ -186,6 +274,7 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Position }
 func (ie *IfExpression) String() string {
 	var buffer bytes.Buffer
 
@@ -202,6 +291,37 @@ func (ie *IfExpression) String() string {
 	return buffer.String()
 }
 
+// try
+type TryExpression struct {
+	Token     token.Token
+	Block     *BlockStatement
+	Parameter *Identifier // nil without a catch clause
+	Catch     *BlockStatement
+	Finally   *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Pos() token.Position  { return te.Token.Position }
+func (te *TryExpression) String() string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString("try ")
+	buffer.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		buffer.WriteString(" catch (" + te.Parameter.String() + ") ")
+		buffer.WriteString(te.Catch.String())
+	}
+
+	if te.Finally != nil {
+		buffer.WriteString(" finally ")
+		buffer.WriteString(te.Finally.String())
+	}
+
+	return buffer.String()
+}
+
 // Block statement
 type BlockStatement struct {
 	Token      token.Token
@@ -210,6 +330,7 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Position }
 func (bs *BlockStatement) String() string {
 	var buffer bytes.Buffer
 
@@ -224,17 +345,38 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
-	Body       *BlockStatement
+	// Defaults holds the default value of each parameter in Parameters,
+	// nil for the required ones, or is empty if no parameter has one.
+	Defaults []Expression
+	// Rest, if set, is the parameter that collects surplus arguments.
+	Rest *Identifier
+	Body *BlockStatement
+}
+
+// Default returns the default value of the ith parameter, or nil.
+func (fl *FunctionLiteral) Default(i int) Expression {
+	if i < len(fl.Defaults) {
+		return fl.Defaults[i]
+	}
+	return nil
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Position }
 func (fl *FunctionLiteral) String() string {
 	var buffer bytes.Buffer
 
 	params := []string{}
-	for _, param := range fl.Parameters {
-		params = append(params, param.String())
+	for i, param := range fl.Parameters {
+		if value := fl.Default(i); value != nil {
+			params = append(params, param.String()+" = "+value.String())
+		} else {
+			params = append(params, param.String())
+		}
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
 	}
 
 	buffer.WriteString(fl.TokenLiteral())
@@ -251,10 +393,14 @@ type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	// Tail is set on calls whose value the enclosing function returns as
+	// is, which can reuse the caller's stack space.
+	Tail bool
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Token.Position }
 func (ce *CallExpression) String() string {
 	var buffer bytes.Buffer
 
@@ -271,6 +417,29 @@ func (ce *CallExpression) String() string {
 	return buffer.String()
 }
 
+// ...array in the arguments of a call
+type SpreadExpression struct {
+	Token token.Token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Pos() token.Position  { return se.Token.Position }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// name: value in the arguments of a call
+type NamedArgument struct {
+	Token token.Token
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) Pos() token.Position  { return na.Token.Position }
+func (na *NamedArgument) String() string       { return na.Name.String() + ": " + na.Value.String() }
+
 // String
 type StringLiteral struct {
 	Token token.Token
@@ -279,6 +448,7 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Position }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
 // Array literal
@@ -289,6 +459,7 @@ type ArrayLiteral struct {
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Position }
 func (al *ArrayLiteral) String() string {
 	var buffer bytes.Buffer
 
@@ -313,6 +484,7 @@ type IndexExpression struct {
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Position }
 func (ie *IndexExpression) String() string {
 	var buffer bytes.Buffer
 
@@ -325,20 +497,49 @@ func (ie *IndexExpression) String() string {
 	return buffer.String()
 }
 
+// import "path"
+type ImportExpression struct {
+	Token token.Token
+	Path  *StringLiteral
+}
+
+func (ie *ImportExpression) expressionNode()      {}
+func (ie *ImportExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *ImportExpression) Pos() token.Position  { return ie.Token.Position }
+func (ie *ImportExpression) String() string {
+	return ie.TokenLiteral() + " " + strconv.Quote(ie.Path.Value)
+}
+
+// module.name
+type MemberExpression struct {
+	Token  token.Token
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) Pos() token.Position  { return me.Token.Position }
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Member.String() + ")"
+}
+
 // hash
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	Keys  []Expression // the keys of Pairs in source order
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Position }
 func (hl *HashLiteral) String() string {
 	var buffer bytes.Buffer
 
 	pairs := []string{}
-	for k, v := range hl.Pairs {
-		pairs = append(pairs, k.String()+":"+v.String())
+	for _, k := range hl.Keys {
+		pairs = append(pairs, k.String()+":"+hl.Pairs[k].String())
 	}
 
 	buffer.WriteString("{")
@@ -347,3 +548,93 @@ func (hl *HashLiteral) String() string {
 
 	return buffer.String()
 }
+
+// while
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Position }
+func (ws *WhileStatement) String() string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString("while")
+	buffer.WriteString(ws.Condition.String())
+	buffer.WriteString(" ")
+	buffer.WriteString(ws.Body.String())
+
+	return buffer.String()
+}
+
+// for-in
+type ForStatement struct {
+	Token    token.Token
+	Key      *Identifier // nil unless two variables are given
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Position }
+func (fs *ForStatement) String() string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString("for (")
+	if fs.Key != nil {
+		buffer.WriteString(fs.Key.String() + ", ")
+	}
+	buffer.WriteString(fs.Value.String())
+	buffer.WriteString(" in ")
+	buffer.WriteString(fs.Iterable.String())
+	buffer.WriteString(") ")
+	buffer.WriteString(fs.Body.String())
+
+	return buffer.String()
+}
+
+// throw
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position  { return ts.Token.Position }
+func (ts *ThrowStatement) String() string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		buffer.WriteString(ts.Value.String())
+	}
+	buffer.WriteString(";")
+
+	return buffer.String()
+}
+
+// break
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Position }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+// continue
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Position }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
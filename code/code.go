@@ -0,0 +1,245 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"interpreter/token"
+	"sort"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i += 1
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpDup
+	OpDup2
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpInfix
+	OpPrefix
+
+	OpJump
+	OpJumpNotTruthy
+
+	OpGetGlobal
+	OpSetGlobal
+	OpAssignGlobal
+	OpGetLocal
+	OpSetLocal
+	OpAssignLocal
+	OpGetFree
+	OpAssignFree
+	OpGetBuiltin
+
+	OpArray
+	OpHash
+	OpIndex
+	OpSetIndex
+
+	OpClosure
+	OpCloseUpvalues
+	OpCall
+	OpReturnValue
+	OpReturn
+
+	OpIterInit
+	OpIterNext
+
+	OpFail
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+	OpDup:      {"OpDup", []int{}},
+	OpDup2:     {"OpDup2", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	// The operand is an index into InfixOperators or PrefixOperators.
+	OpInfix:  {"OpInfix", []int{1}},
+	OpPrefix: {"OpPrefix", []int{1}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	// Set defines a binding and pops the value, Assign updates an existing
+	// one and leaves the value on the stack as the result of the expression.
+	OpGetGlobal:    {"OpGetGlobal", []int{2}},
+	OpSetGlobal:    {"OpSetGlobal", []int{2}},
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+	OpGetLocal:     {"OpGetLocal", []int{2}},
+	OpSetLocal:     {"OpSetLocal", []int{2}},
+	OpAssignLocal:  {"OpAssignLocal", []int{2}},
+	OpGetFree:      {"OpGetFree", []int{1}},
+	OpAssignFree:   {"OpAssignFree", []int{1}},
+	OpGetBuiltin:   {"OpGetBuiltin", []int{1}},
+
+	OpArray:    {"OpArray", []int{2}},
+	OpHash:     {"OpHash", []int{2}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpSetIndex: {"OpSetIndex", []int{}},
+
+	OpClosure:       {"OpClosure", []int{2}},
+	OpCloseUpvalues: {"OpCloseUpvalues", []int{2}},
+	OpCall:          {"OpCall", []int{1}},
+	OpReturnValue:   {"OpReturnValue", []int{}},
+	OpReturn:        {"OpReturn", []int{}},
+
+	// OpIterNext jumps to its first operand once the iterator is exhausted.
+	// Its second operand is the number of loop variables to push.
+	OpIterInit: {"OpIterInit", []int{}},
+	OpIterNext: {"OpIterNext", []int{2, 1}},
+
+	// OpFail raises a runtime error whose message is the constant operand.
+	OpFail: {"OpFail", []int{2}},
+}
+
+var InfixOperators = []string{"+", "-", "*", "/", "<", ">", "==", "!=", "<>", "<=", ">=", "%", "**", "&", "|", "^", "<<", ">>", "div"}
+
+var PrefixOperators = []string{"!", "-"}
+
+// OperatorIndex returns the operand that encodes operator in OpInfix or
+// OpPrefix, or -1 if operators does not contain it.
+func OperatorIndex(operators []string, operator string) int {
+	for i, op := range operators {
+		if op == operator {
+			return i
+		}
+	}
+	return -1
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
+
+// SourceMap maps instruction offsets back to the source position of the node
+// they were compiled from. Entries are sorted by offset and an entry covers
+// every instruction up to the next one.
+type SourceMap []SourcePosition
+
+type SourcePosition struct {
+	Offset   int
+	Position token.Position
+}
+
+func (sm SourceMap) Lookup(offset int) token.Position {
+	i := sort.Search(len(sm), func(i int) bool { return sm[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+	return sm[i-1].Position
+}
@@ -0,0 +1,116 @@
+package code
+
+import (
+	"interpreter/token"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpPop, []int{}, []byte{byte(OpPop)}},
+		{OpGetFree, []int{255}, []byte{byte(OpGetFree), 255}},
+		{OpIterNext, []int{65534, 2}, []byte{byte(OpIterNext), 255, 254, 2}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Errorf("instruction has wrong length. want=%d, got=%d", len(tt.expected), len(instruction))
+		}
+
+		for i, b := range tt.expected {
+			if instruction[i] != tt.expected[i] {
+				t.Errorf("wrong byte at pos %d. want=%d, got=%d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpInfix, 0),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpIterNext, 12, 1),
+	}
+
+	expected := `0000 OpInfix 0
+0002 OpGetLocal 1
+0005 OpConstant 2
+0008 OpConstant 65535
+0011 OpIterNext 12 1
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetFree, []int{255}, 1},
+		{OpIterNext, []int{300, 2}, 3},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q\n", err)
+		}
+
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("n wrong. want=%d, got=%d", tt.bytesRead, n)
+		}
+
+		for i, want := range tt.operands {
+			if operandsRead[i] != want {
+				t.Errorf("operand wrong. want=%d, got=%d", want, operandsRead[i])
+			}
+		}
+	}
+}
+
+func TestSourceMapLookup(t *testing.T) {
+	sourceMap := SourceMap{
+		{Offset: 0, Position: token.Position{Line: 1, Column: 1}},
+		{Offset: 5, Position: token.Position{Line: 2, Column: 3}},
+		{Offset: 9, Position: token.Position{Line: 4, Column: 1}},
+	}
+
+	tests := []struct {
+		offset   int
+		expected string
+	}{
+		{0, "1:1"},
+		{4, "1:1"},
+		{5, "2:3"},
+		{8, "2:3"},
+		{20, "4:1"},
+	}
+
+	for _, tt := range tests {
+		if got := sourceMap.Lookup(tt.offset).String(); got != tt.expected {
+			t.Errorf("wrong position for offset %d. want=%s, got=%s", tt.offset, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,684 @@
+package compiler
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/code"
+	"interpreter/object"
+	"interpreter/token"
+	"strings"
+)
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	sourceMap           code.SourceMap
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loop
+}
+
+// loop collects the jumps emitted for break and continue until the loop's
+// exit and continue targets are known.
+type loop struct {
+	breakJumps    []int
+	continueJumps []int
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	scopes      []CompilationScope
+	scopeIndex  int
+	position    token.Position
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	SourceMap    code.SourceMap
+	Constants    []object.Object
+	NumLocals    int
+	LocalNames   []string
+	GlobalNames  []string
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// NewWithState returns a compiler that keeps adding to an existing symbol
+// table and constant pool, so globals survive between REPL inputs.
+func NewWithState(symbolTable *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{{}},
+		scopeIndex:  0,
+	}
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	if node == nil {
+		return nil
+	}
+
+	previousPosition := c.position
+	if position := node.Pos(); position.IsValid() {
+		c.position = position
+	}
+	defer func() { c.position = previousPosition }()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+
+	case *ast.BreakStatement:
+		c.compileLoopJump(node.Token.Literal)
+
+	case *ast.ContinueStatement:
+		c.compileLoopJump(node.Token.Literal)
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+		if err := c.Compile(node.LeftOperand); err != nil {
+			return err
+		}
+		if err := c.Compile(node.RightOperand); err != nil {
+			return err
+		}
+		return c.emitOperator(code.OpInfix, code.InfixOperators, node.Operator)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Operand); err != nil {
+			return err
+		}
+		return c.emitOperator(code.OpPrefix, code.PrefixOperators, node.Operator)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+
+	case *ast.Identifier:
+		c.loadSymbol(c.symbolTable.Resolve(node.Value))
+
+	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			c.emit(code.OpConstant, c.addConstant(&object.BigInt{Value: node.Big}))
+		} else {
+			c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+		}
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, key := range node.Keys {
+			if err := c.Compile(key); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[key]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Collection); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+
+	default:
+		return fmt.Errorf("%s: cannot compile %s", node.Pos(), construct(node))
+	}
+
+	return nil
+}
+
+// construct names the language construct of a node the compiler does not
+// support, for error messages.
+func construct(node ast.Node) string {
+	switch node.(type) {
+	case *ast.TryExpression:
+		return "try expressions"
+	case *ast.ThrowStatement:
+		return "throw statements"
+	case *ast.ImportStatement, *ast.ImportExpression, *ast.MemberExpression:
+		return "modules"
+	case *ast.ExportStatement:
+		return "export statements"
+	case *ast.SpreadExpression:
+		return "spread arguments"
+	case *ast.NamedArgument:
+		return "named arguments"
+	default:
+		return "`" + node.TokenLiteral() + "`"
+	}
+}
+
+// Let statements bind a function before compiling it so that the function
+// can call itself. Other values are compiled first, so `let x = x + 1` in an
+// inner scope still reads the outer x, as it does in the evaluator.
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	if function, ok := node.Value.(*ast.FunctionLiteral); ok {
+		symbol := c.symbolTable.Define(node.Name.Value)
+		if err := c.compileFunctionLiteral(function, node.Name.Value); err != nil {
+			return err
+		}
+		c.storeSymbol(symbol)
+		return nil
+	}
+
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+	c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPosition := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPosition := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPosition, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPosition, len(c.currentInstructions()))
+	return nil
+}
+
+// compileLogicalExpression leaves the left operand on the stack when it
+// decides the result and otherwise replaces it with the right operand, which
+// is only evaluated in that case.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.LeftOperand); err != nil {
+		return err
+	}
+	c.emit(code.OpDup)
+
+	var jumpPosition int
+	if node.Operator == "&&" {
+		jumpPosition = c.emit(code.OpJumpNotTruthy, 9999)
+	} else {
+		jumpNotTruthyPosition := c.emit(code.OpJumpNotTruthy, 9999)
+		jumpPosition = c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPosition, len(c.currentInstructions()))
+	}
+
+	c.emit(code.OpPop)
+	if err := c.Compile(node.RightOperand); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPosition, len(c.currentInstructions()))
+	return nil
+}
+
+// compileBlockValue compiles a block that is used as a value and leaves the
+// value of its last expression on the stack, or null if it has none.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	loopStart := len(c.currentInstructions())
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exitJump := c.emit(code.OpJumpNotTruthy, 9999)
+
+	current := c.enterLoop()
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.leaveLoop()
+
+	c.patchJumps(current.continueJumps, loopStart)
+	c.emit(code.OpJump, loopStart)
+
+	exit := len(c.currentInstructions())
+	c.changeOperand(exitJump, exit)
+	c.patchJumps(current.breakJumps, exit)
+
+	// Like every statement that is not an expression, a loop evaluates to
+	// null; pushing and popping it keeps the VM's result in step with Eval.
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+	return nil
+}
+
+// The loop variables and everything the body defines live in a block scope
+// whose captured variables are closed at the end of every iteration, so a
+// closure created in the body keeps that iteration's values.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIterInit)
+
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	firstSlot := c.symbolTable.function.numLocals
+
+	loopStart := len(c.currentInstructions())
+	variables := 1
+	if node.Key != nil {
+		variables = 2
+	}
+	exitJump := c.emit(code.OpIterNext, 9999, variables)
+
+	c.storeSymbol(c.symbolTable.Define(node.Value.Value))
+	if node.Key != nil {
+		c.storeSymbol(c.symbolTable.Define(node.Key.Value))
+	}
+
+	current := c.enterLoop()
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.leaveLoop()
+
+	c.patchJumps(current.continueJumps, len(c.currentInstructions()))
+	c.emit(code.OpCloseUpvalues, firstSlot)
+	c.emit(code.OpJump, loopStart)
+
+	c.patchJumps(current.breakJumps, len(c.currentInstructions()))
+	c.emit(code.OpCloseUpvalues, firstSlot)
+
+	c.changeOperand(exitJump, len(c.currentInstructions()))
+	c.symbolTable = c.symbolTable.Outer
+
+	c.emit(code.OpPop)
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+	return nil
+}
+
+// compileLoopJump compiles break or continue. Outside of a loop it compiles
+// to the same runtime error the evaluator reports when the statement runs.
+func (c *Compiler) compileLoopJump(keyword string) {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		message := &object.String{Value: keyword + " outside of a loop"}
+		c.emit(code.OpFail, c.addConstant(message))
+		return
+	}
+
+	current := loops[len(loops)-1]
+	jump := c.emit(code.OpJump, 9999)
+	if keyword == "break" {
+		current.breakJumps = append(current.breakJumps, jump)
+	} else {
+		current.continueJumps = append(current.continueJumps, jump)
+	}
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	compound := node.Operator != "="
+	operator := strings.TrimSuffix(node.Operator, "=")
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol := c.symbolTable.Resolve(target.Value)
+		if compound {
+			c.loadSymbol(symbol)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			if err := c.emitOperator(code.OpInfix, code.InfixOperators, operator); err != nil {
+				return err
+			}
+		}
+		return c.assignSymbol(symbol)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Collection); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(code.OpDup2)
+			c.emit(code.OpIndex)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			if err := c.emitOperator(code.OpInfix, code.InfixOperators, operator); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSetIndex)
+		return nil
+
+	default:
+		return fmt.Errorf("%s: cannot assign to %s", node.Pos(), node.Target.String())
+	}
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, name string) error {
+	if len(node.Defaults) > 0 || node.Rest != nil {
+		return fmt.Errorf("%s: cannot compile default or rest parameters", node.Pos())
+	}
+
+	c.enterScope()
+
+	parameters := make([]string, len(node.Parameters))
+	for i, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+		parameters[i] = p.Value
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numLocals
+	localNames := c.symbolTable.localNames
+	instructions, sourceMap := c.leaveScope()
+
+	captures := make([]object.Capture, len(freeSymbols))
+	for i, s := range freeSymbols {
+		captures[i] = object.Capture{Name: s.Name, Local: s.Scope == LocalScope, Index: s.Index}
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Name:          name,
+		Instructions:  instructions,
+		SourceMap:     sourceMap,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Parameters:    parameters,
+		Captures:      captures,
+		LocalNames:    localNames,
+	}
+
+	c.emit(code.OpClosure, c.addConstant(compiledFn))
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) assignSymbol(s Symbol) error {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpAssignGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpAssignLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpAssignFree, s.Index)
+	default:
+		message := &object.String{Value: "cannot assign to undefined variable " + s.Name}
+		c.emit(code.OpFail, c.addConstant(message))
+	}
+	return nil
+}
+
+func (c *Compiler) emitOperator(op code.Opcode, operators []string, operator string) error {
+	index := code.OperatorIndex(operators, operator)
+	if index < 0 {
+		return fmt.Errorf("%s: unknown operator %s", c.position, operator)
+	}
+	c.emit(op, index)
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		Constants:    c.constants,
+		NumLocals:    c.symbolTable.function.numLocals,
+		LocalNames:   c.symbolTable.function.localNames,
+		GlobalNames:  c.symbolTable.GlobalNames(),
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	scope := &c.scopes[c.scopeIndex]
+	posNewInstruction := len(scope.instructions)
+
+	last := len(scope.sourceMap) - 1
+	if last < 0 || scope.sourceMap[last].Position != c.position {
+		scope.sourceMap = append(scope.sourceMap, code.SourcePosition{Offset: posNewInstruction, Position: c.position})
+	}
+
+	scope.instructions = append(scope.instructions, ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	scope := &c.scopes[c.scopeIndex]
+	last := scope.lastInstruction
+
+	scope.instructions = scope.instructions[:last.Position]
+	for len(scope.sourceMap) > 0 && scope.sourceMap[len(scope.sourceMap)-1].Offset >= last.Position {
+		scope.sourceMap = scope.sourceMap[:len(scope.sourceMap)-1]
+	}
+	scope.lastInstruction = scope.previousInstruction
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	def, _ := code.Lookup(byte(op))
+
+	// Keep any further operands, such as the variable count of OpIterNext.
+	operands, _ := code.ReadOperands(def, c.currentInstructions()[opPos+1:])
+	operands[0] = operand
+
+	c.replaceInstruction(opPos, code.Make(op, operands...))
+}
+
+func (c *Compiler) patchJumps(jumps []int, target int) {
+	for _, jump := range jumps {
+		c.changeOperand(jump, target)
+	}
+}
+
+func (c *Compiler) enterLoop() *loop {
+	current := &loop{}
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, current)
+	return current
+}
+
+func (c *Compiler) leaveLoop() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex += 1
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, code.SourceMap) {
+	instructions := c.currentInstructions()
+	sourceMap := c.scopes[c.scopeIndex].sourceMap
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex -= 1
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions, sourceMap
+}
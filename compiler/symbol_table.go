@@ -0,0 +1,129 @@
+package compiler
+
+import "interpreter/evaluator"
+
+type SymbolScope string
+
+const (
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	BuiltinScope SymbolScope = "BUILTIN"
+	FreeScope    SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable resolves names to storage slots. There are three kinds of
+// table: the global table, one table per function, and block tables for the
+// body of a for loop. A block table gets its own names but allocates its
+// slots from the function that contains it (the global table counts as the
+// function for top-level blocks, whose slots live in the main frame).
+type SymbolTable struct {
+	Outer       *SymbolTable
+	FreeSymbols []Symbol
+
+	store          map[string]Symbol
+	function       *SymbolTable
+	numDefinitions int
+	numLocals      int
+	localNames     []string
+	globalNames    []string
+}
+
+func NewSymbolTable() *SymbolTable {
+	table := &SymbolTable{store: map[string]Symbol{}}
+	table.function = table
+	return table
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	table := NewSymbolTable()
+	table.Outer = outer
+	return table
+}
+
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	table := NewSymbolTable()
+	table.Outer = outer
+	table.function = outer.function
+	return table
+}
+
+func (s *SymbolTable) isGlobal() bool { return s.Outer == nil }
+
+func (s *SymbolTable) isBlock() bool { return s.function != s }
+
+// Define binds name in this table. Redefining a name in the same table
+// reuses its slot, the way `let` overwrites a binding in the evaluator's
+// environment.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		return symbol
+	}
+
+	var symbol Symbol
+	if s.isGlobal() {
+		symbol = Symbol{Name: name, Scope: GlobalScope, Index: s.numDefinitions}
+		s.numDefinitions += 1
+		s.globalNames = append(s.globalNames, name)
+	} else {
+		owner := s.function
+		symbol = Symbol{Name: name, Scope: LocalScope, Index: owner.numLocals}
+		owner.numLocals += 1
+		owner.localNames = append(owner.localNames, name)
+	}
+
+	s.store[name] = symbol
+	return symbol
+}
+
+// Resolve looks name up through the enclosing tables. A local of an
+// enclosing function becomes a free variable of this one. Names that are
+// not defined anywhere resolve to a builtin, or else to a global that has
+// not been assigned yet, since the evaluator looks globals up by name at
+// run time and a function may refer to one defined after it.
+func (s *SymbolTable) Resolve(name string) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+
+	if s.isGlobal() {
+		if index := builtinIndex(name); index >= 0 {
+			return Symbol{Name: name, Scope: BuiltinScope, Index: index}
+		}
+		return s.Define(name)
+	}
+
+	symbol := s.Outer.Resolve(name)
+	if s.isBlock() || symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+		return symbol
+	}
+
+	return s.defineFree(symbol)
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// GlobalNames returns the names of the globals by slot, for error messages.
+func (s *SymbolTable) GlobalNames() []string {
+	return s.globalNames
+}
+
+func builtinIndex(name string) int {
+	for i, builtin := range evaluator.BuiltinNames() {
+		if builtin == name {
+			return i
+		}
+	}
+	return -1
+}
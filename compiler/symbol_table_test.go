@@ -0,0 +1,104 @@
+package compiler
+
+import "testing"
+
+func TestDefineAndResolve(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	b := global.Define("b")
+
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) || b != (Symbol{Name: "b", Scope: GlobalScope, Index: 1}) {
+		t.Fatalf("wrong global symbols. got=%+v, %+v", a, b)
+	}
+
+	if redefined := global.Define("a"); redefined != a {
+		t.Errorf("redefining a global should reuse its slot. got=%+v", redefined)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	c := local.Define("c")
+	if c != (Symbol{Name: "c", Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong local symbol. got=%+v", c)
+	}
+
+	if resolved := local.Resolve("a"); resolved != a {
+		t.Errorf("expected global a. got=%+v", resolved)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	outer := NewEnclosedSymbolTable(global)
+	outer.Define("b")
+
+	inner := NewEnclosedSymbolTable(outer)
+	inner.Define("c")
+
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: FreeScope, Index: 0},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+	}
+
+	for name, want := range expected {
+		if got := inner.Resolve(name); got != want {
+			t.Errorf("%s resolved wrong. want=%+v, got=%+v", name, want, got)
+		}
+	}
+
+	if len(inner.FreeSymbols) != 1 || inner.FreeSymbols[0] != (Symbol{Name: "b", Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong free symbols. got=%+v", inner.FreeSymbols)
+	}
+}
+
+func TestBlockScopes(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("x")
+
+	block := NewBlockSymbolTable(global)
+	shadow := block.Define("x")
+	if shadow != (Symbol{Name: "x", Scope: LocalScope, Index: 0}) {
+		t.Errorf("block variable at top level should be a main frame local. got=%+v", shadow)
+	}
+
+	function := NewEnclosedSymbolTable(global)
+	function.Define("p")
+	nested := NewBlockSymbolTable(function)
+	v := nested.Define("v")
+	if v != (Symbol{Name: "v", Scope: LocalScope, Index: 1}) {
+		t.Errorf("block variable should take the next slot of its function. got=%+v", v)
+	}
+
+	if got := nested.Resolve("p"); got != (Symbol{Name: "p", Scope: LocalScope, Index: 0}) {
+		t.Errorf("block should resolve its function's locals directly. got=%+v", got)
+	}
+
+	closure := NewEnclosedSymbolTable(nested)
+	if got := closure.Resolve("v"); got != (Symbol{Name: "v", Scope: FreeScope, Index: 0}) {
+		t.Errorf("block variable should be free in a nested function. got=%+v", got)
+	}
+
+	if function.numLocals != 2 {
+		t.Errorf("function should own the block's slot. numLocals=%d", function.numLocals)
+	}
+}
+
+func TestResolveUndefined(t *testing.T) {
+	global := NewSymbolTable()
+	local := NewEnclosedSymbolTable(global)
+
+	if got := local.Resolve("len"); got.Scope != BuiltinScope {
+		t.Errorf("expected builtin len. got=%+v", got)
+	}
+
+	later := local.Resolve("later")
+	if later != (Symbol{Name: "later", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("undefined name should resolve to a new global. got=%+v", later)
+	}
+
+	if defined := global.Define("later"); defined != later {
+		t.Errorf("defining the global later should reuse its slot. got=%+v", defined)
+	}
+}
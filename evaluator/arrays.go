@@ -0,0 +1,473 @@
+package evaluator
+
+import (
+	"interpreter/object"
+	"math"
+	"sort"
+)
+
+// Array builtins never modify their argument: push, pop and the rest
+// return a new array. The higher-order ones call their function argument
+// with each element and, if the function has at least two parameters
+// without defaults, its index.
+
+func builtInFirst(args ...object.Object) object.Object {
+	array, err := singleArray("first", args)
+	if err != nil {
+		return err
+	}
+	if len(array) == 0 {
+		return NULL
+	}
+	return array[0]
+}
+
+func builtInLast(args ...object.Object) object.Object {
+	array, err := singleArray("last", args)
+	if err != nil {
+		return err
+	}
+	if len(array) == 0 {
+		return NULL
+	}
+	return array[len(array)-1]
+}
+
+// builtInRest returns all but the first element, or null for an empty
+// array.
+func builtInRest(args ...object.Object) object.Object {
+	array, err := singleArray("rest", args)
+	if err != nil {
+		return err
+	}
+	if len(array) == 0 {
+		return NULL
+	}
+	return newArray(array[1:])
+}
+
+// builtInPush returns a copy of the array with the remaining arguments
+// appended.
+func builtInPush(meter *object.Meter, args ...object.Object) object.Object {
+	if err := checkArity(args, 2, -1); err != nil {
+		return err
+	}
+	array, err := arrayArg("push", args, 0)
+	if err != nil {
+		return err
+	}
+	if err := checkArraySize(meter, "push", int64(len(array)+len(args)-1)); err != nil {
+		return err
+	}
+	return newArray(array, args[1:]...)
+}
+
+// builtInPop returns a copy of the array without its last element; last
+// gives the element itself.
+func builtInPop(args ...object.Object) object.Object {
+	array, err := singleArray("pop", args)
+	if err != nil {
+		return err
+	}
+	if len(array) == 0 {
+		return newError("pop from empty ARRAY")
+	}
+	return newArray(array[:len(array)-1])
+}
+
+func builtInReverse(args ...object.Object) object.Object {
+	if err := checkArity(args, 1, 1); err != nil {
+		return err
+	}
+	switch arg := args[0].(type) {
+	case *object.Array:
+		elements := newArray(arg.Elements)
+		reverse(elements.Elements)
+		return elements
+	case *object.String:
+		runes := []rune(arg.Value)
+		reverse(runes)
+		return &object.String{Value: string(runes)}
+	default:
+		return newError("argument to `reverse` not supported, got %s", args[0].Type())
+	}
+}
+
+func reverse[T any](s []T) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// builtInSort returns the elements in ascending order. Without a
+// comparator the elements must all be numbers, all strings or all arrays,
+// which are ordered as by <. A comparator is called with two elements and
+// returns true, or a negative integer, if the first belongs before the
+// second.
+func builtInSort(call object.Caller, args ...object.Object) object.Object {
+	if err := checkArity(args, 1, 2); err != nil {
+		return err
+	}
+	array, err := arrayArg("sort", args, 0)
+	if err != nil {
+		return err
+	}
+	sorted := newArray(array)
+
+	var failure object.Object
+	less := func(a, b object.Object) bool {
+		if failure != nil {
+			return false
+		}
+		result := compareNatural(a, b)
+		if len(args) == 2 {
+			result = call(args[1], a, b)
+		}
+		switch result := result.(type) {
+		case *object.Boolean:
+			return result.Value
+		case *object.Integer:
+			return result.Value < 0
+		case *object.BigInt:
+			return result.Value.Sign() < 0
+		case *object.Error:
+			failure = result
+		default:
+			failure = newError("comparator for `sort` must return BOOLEAN or INTEGER, got %s", result.Type())
+		}
+		return false
+	}
+
+	elements := sorted.Elements
+	sort.SliceStable(elements, func(i, j int) bool { return less(elements[i], elements[j]) })
+	if failure != nil {
+		return failure
+	}
+	return sorted
+}
+
+func compareNatural(a, b object.Object) object.Object {
+	order, err := compareObjects("<", a, b, nil)
+	if err != nil {
+		return newError("cannot sort %s and %s without a comparator", a.Type(), b.Type())
+	}
+	return &object.Integer{Value: int64(order)}
+}
+
+func builtInMap(call object.Caller, args ...object.Object) object.Object {
+	array, fn, err := arrayAndFunction("map", args)
+	if err != nil {
+		return err
+	}
+	mapped := make([]object.Object, len(array))
+	for i, element := range array {
+		result := callWithIndex(call, fn, element, i)
+		if isError(result) {
+			return result
+		}
+		mapped[i] = result
+	}
+	return &object.Array{Elements: mapped}
+}
+
+func builtInFilter(call object.Caller, args ...object.Object) object.Object {
+	array, fn, err := arrayAndFunction("filter", args)
+	if err != nil {
+		return err
+	}
+	kept := []object.Object{}
+	for i, element := range array {
+		result := callWithIndex(call, fn, element, i)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			kept = append(kept, element)
+		}
+	}
+	return &object.Array{Elements: kept}
+}
+
+// builtInReduce folds the array from the left with fn(accumulator,
+// element). Without an initial value the first element is used, and an
+// empty array is an error.
+func builtInReduce(call object.Caller, args ...object.Object) object.Object {
+	if err := checkArity(args, 2, 3); err != nil {
+		return err
+	}
+	array, fn, err := arrayAndFunction("reduce", args[:2])
+	if err != nil {
+		return err
+	}
+
+	var accumulator object.Object
+	if len(args) == 3 {
+		accumulator = args[2]
+	} else if len(array) == 0 {
+		return newError("reduce of empty ARRAY with no initial value")
+	} else {
+		accumulator, array = array[0], array[1:]
+	}
+
+	for _, element := range array {
+		accumulator = call(fn, accumulator, element)
+		if isError(accumulator) {
+			return accumulator
+		}
+	}
+	return accumulator
+}
+
+// builtInFind returns the first element for which fn is truthy, or null.
+func builtInFind(call object.Caller, args ...object.Object) object.Object {
+	array, fn, err := arrayAndFunction("find", args)
+	if err != nil {
+		return err
+	}
+	for i, element := range array {
+		result := callWithIndex(call, fn, element, i)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			return element
+		}
+	}
+	return NULL
+}
+
+// quantifier makes any and all, which stop at the first element for which
+// fn is truthy (any) or falsy (all).
+func quantifier(name string, want bool) object.HigherOrderFunction {
+	return func(call object.Caller, args ...object.Object) object.Object {
+		array, fn, err := arrayAndFunction(name, args)
+		if err != nil {
+			return err
+		}
+		for i, element := range array {
+			result := callWithIndex(call, fn, element, i)
+			if isError(result) {
+				return result
+			}
+			if isTruthy(result) == want {
+				return nativeBoolToBooleanObject(want)
+			}
+		}
+		return nativeBoolToBooleanObject(!want)
+	}
+}
+
+// builtInZip pairs up the elements of its arguments, stopping at the end
+// of the shortest array.
+func builtInZip(meter *object.Meter, args ...object.Object) object.Object {
+	if err := checkArity(args, 1, -1); err != nil {
+		return err
+	}
+	arrays := make([][]object.Object, len(args))
+	length := math.MaxInt
+	for i := range args {
+		array, err := arrayArg("zip", args, i)
+		if err != nil {
+			return err
+		}
+		arrays[i] = array
+		length = min(length, len(array))
+	}
+	// Each pair is an array of its own.
+	if err := checkArraySize(meter, "zip", int64(length)*int64(1+len(arrays))); err != nil {
+		return err
+	}
+
+	zipped := make([]object.Object, length)
+	for i := range zipped {
+		tuple := make([]object.Object, len(arrays))
+		for j, array := range arrays {
+			tuple[j] = array[i]
+		}
+		zipped[i] = &object.Array{Elements: tuple}
+	}
+	return &object.Array{Elements: zipped}
+}
+
+// builtInFlatten splices nested arrays into their parent, one level deep
+// or as many levels as the optional depth says.
+func builtInFlatten(meter *object.Meter, args ...object.Object) object.Object {
+	if err := checkArity(args, 1, 2); err != nil {
+		return err
+	}
+	array, err := arrayArg("flatten", args, 0)
+	if err != nil {
+		return err
+	}
+	depth := int64(1)
+	if len(args) == 2 {
+		if depth, err = integerArg("flatten", args, 1); err != nil {
+			return err
+		}
+	}
+	length, err := flattenedLength(array, depth, map[*object.Array]bool{})
+	if err != nil {
+		return err
+	}
+	if err := checkArraySize(meter, "flatten", length); err != nil {
+		return err
+	}
+	return &object.Array{Elements: flatten(make([]object.Object, 0, length), array, depth)}
+}
+
+// flattenedLength counts the elements flatten gives, stopping once there
+// are more than maxArrayLength. An array that contains itself within depth
+// levels has no end.
+func flattenedLength(elements []object.Object, depth int64, open map[*object.Array]bool) (int64, *object.Error) {
+	var length int64
+	for _, element := range elements {
+		nested, ok := element.(*object.Array)
+		if !ok || depth <= 0 {
+			length++
+			continue
+		}
+		if open[nested] {
+			return 0, newError("cannot flatten an ARRAY that contains itself")
+		}
+		open[nested] = true
+		n, err := flattenedLength(nested.Elements, depth-1, open)
+		delete(open, nested)
+		if err != nil {
+			return 0, err
+		}
+		if length += n; length > maxArrayLength {
+			return length, nil
+		}
+	}
+	return length, nil
+}
+
+func flatten(into, elements []object.Object, depth int64) []object.Object {
+	for _, element := range elements {
+		if nested, ok := element.(*object.Array); ok && depth > 0 {
+			into = flatten(into, nested.Elements, depth-1)
+		} else {
+			into = append(into, element)
+		}
+	}
+	return into
+}
+
+// builtInRange returns the integers from start (default 0) up to, but not
+// including, end, counting by step (default 1).
+func builtInRange(meter *object.Meter, args ...object.Object) object.Object {
+	if err := checkArity(args, 1, 3); err != nil {
+		return err
+	}
+	var bounds [3]int64
+	for i := range args {
+		n, err := integerArg("range", args, i)
+		if err != nil {
+			return err
+		}
+		bounds[i] = n
+	}
+
+	start, end, step := int64(0), bounds[0], int64(1)
+	if len(args) > 1 {
+		start, end = bounds[0], bounds[1]
+	}
+	if len(args) > 2 {
+		step = bounds[2]
+	}
+	if step == 0 {
+		return newError("range step must not be 0")
+	}
+
+	// The span between two int64s can exceed int64, but never uint64.
+	var count uint64
+	if step > 0 && end > start {
+		count = (uint64(end)-uint64(start)-1)/uint64(step) + 1
+	} else if step < 0 && end < start {
+		count = (uint64(start)-uint64(end)-1)/-uint64(step) + 1
+	}
+	if count > maxArrayLength {
+		return newError("result of `range` too large: %d elements exceeds %d", count, maxArrayLength)
+	}
+	if err := checkArraySize(meter, "range", int64(count)); err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, count)
+	for i := range elements {
+		elements[i] = &object.Integer{Value: start + int64(i)*step}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func arrayIndexOf(elements []object.Object, value object.Object) int {
+	for i, element := range elements {
+		if objectsEqual(element, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// callWithIndex passes the index along with the element to script
+// functions that require more than one argument. Parameters with defaults
+// and rest parameters do not count, so they keep their default values.
+// Builtins get only the element.
+func callWithIndex(call object.Caller, fn, element object.Object, i int) object.Object {
+	if !takesIndex(fn) {
+		return call(fn, element)
+	}
+	return call(fn, element, &object.Integer{Value: int64(i)})
+}
+
+func takesIndex(fn object.Object) bool {
+	switch fn := fn.(type) {
+	case *object.Function:
+		required := 0
+		for i := range fn.Parameters {
+			if fn.Default(i) == nil {
+				required++
+			}
+		}
+		return required > 1
+	case *object.Closure:
+		return fn.Fn.NumParameters > 1
+	}
+	return false
+}
+
+func newArray(elements []object.Object, more ...object.Object) *object.Array {
+	copied := make([]object.Object, 0, len(elements)+len(more))
+	copied = append(copied, elements...)
+	return &object.Array{Elements: append(copied, more...)}
+}
+
+func singleArray(name string, args []object.Object) ([]object.Object, *object.Error) {
+	if err := checkArity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	return arrayArg(name, args, 0)
+}
+
+func arrayAndFunction(name string, args []object.Object) ([]object.Object, object.Object, *object.Error) {
+	if err := checkArity(args, 2, 2); err != nil {
+		return nil, nil, err
+	}
+	array, err := arrayArg(name, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch args[1].(type) {
+	case *object.Function, *object.Closure, *object.BuiltIn:
+		return array, args[1], nil
+	}
+	return nil, nil, newError("argument 2 to `%s` must be FUNCTION, got %s", name, args[1].Type())
+}
+
+func arrayArg(name string, args []object.Object, i int) ([]object.Object, *object.Error) {
+	array, ok := args[i].(*object.Array)
+	if !ok {
+		return nil, newError("argument %d to `%s` must be ARRAY, got %s", i+1, name, args[i].Type())
+	}
+	return array.Elements, nil
+}
@@ -3,13 +3,95 @@ package evaluator
 import (
 	"fmt"
 	"interpreter/object"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.BuiltIn{
-	"len":  {Fn: builtInLen},
-	"puts": {Fn: puts},
+	"len":   {Fn: builtInLen},
+	"puts":  {Fn: puts},
+	"eputs": {Fn: eputs},
+	"int":   {Fn: builtInInt},
+	"float": {Fn: builtInFloat},
+	"error": {Fn: builtInError},
+
+	"byteLen":    {Fn: builtInByteLen},
+	"split":      {Fn: builtInSplit},
+	"join":       {Sized: builtInJoin},
+	"trim":       {Fn: trimmer("trim", strings.TrimSpace, strings.Trim)},
+	"trimLeft":   {Fn: trimmer("trimLeft", trimLeftSpace, strings.TrimLeft)},
+	"trimRight":  {Fn: trimmer("trimRight", trimRightSpace, strings.TrimRight)},
+	"upper":      {Fn: stringMapper("upper", strings.ToUpper)},
+	"lower":      {Fn: stringMapper("lower", strings.ToLower)},
+	"replace":    {Sized: builtInReplace},
+	"contains":   {Fn: builtInContains},
+	"startsWith": {Fn: stringPredicate("startsWith", strings.HasPrefix)},
+	"endsWith":   {Fn: stringPredicate("endsWith", strings.HasSuffix)},
+	"indexOf":    {Fn: builtInIndexOf},
+	"repeat":     {Sized: builtInRepeat},
+	"substring":  {Fn: builtInSubstring},
+	"slice":      {Fn: builtInSlice},
+	"chars":      {Fn: builtInChars},
+	"format":     {Fn: builtInFormat},
+
+	"first":   {Fn: builtInFirst},
+	"last":    {Fn: builtInLast},
+	"rest":    {Fn: builtInRest},
+	"push":    {Sized: builtInPush},
+	"pop":     {Fn: builtInPop},
+	"reverse": {Fn: builtInReverse},
+	"sort":    {HigherOrder: builtInSort},
+	"map":     {HigherOrder: builtInMap},
+	"filter":  {HigherOrder: builtInFilter},
+	"reduce":  {HigherOrder: builtInReduce},
+	"find":    {HigherOrder: builtInFind},
+	"any":     {HigherOrder: quantifier("any", true)},
+	"all":     {HigherOrder: quantifier("all", false)},
+	"zip":     {Sized: builtInZip},
+	"flatten": {Sized: builtInFlatten},
+	"range":   {Sized: builtInRange},
+
+	"keys":    {Fn: builtInKeys},
+	"values":  {Fn: builtInValues},
+	"entries": {Fn: builtInEntries},
+	"has":     {Fn: builtInHas},
+	"delete":  {Fn: builtInDelete},
+	"merge":   {Fn: builtInMerge},
+	"get":     {Fn: builtInGet},
+	"toArray": {Fn: builtInToArray},
+}
+
+// maxArrayLength and maxStringLength bound the arrays and strings that one
+// call of a builtin creates, so that a short call cannot exhaust memory even
+// when no allocation limit is set.
+const (
+	maxArrayLength  = 1 << 22
+	maxStringLength = 1 << 24
+)
+
+// checkArraySize refuses to create an array of length elements that would
+// exceed maxArrayLength or the allocation budget.
+func checkArraySize(meter *object.Meter, name string, length int64) *object.Error {
+	if length > maxArrayLength {
+		return newError("result of `%s` too large: %d elements exceeds %d", name, length, maxArrayLength)
+	}
+	return meter.Check(1 + length)
+}
+
+// checkStringSize is checkArraySize for a string of length bytes.
+func checkStringSize(meter *object.Meter, name string, length int64) *object.Error {
+	if length > maxStringLength {
+		return newError("result of `%s` too large: %d bytes exceeds %d", name, length, maxStringLength)
+	}
+	return meter.Check(1 + length/8)
 }
 
+// builtInLen counts the characters of a string; byteLen counts its bytes.
 func builtInLen(args ...object.Object) object.Object {
 	if len(args) != 1 {
 		return newError("wrong number of arguments. got=%d, want=1", len(args))
@@ -17,7 +99,7 @@ func builtInLen(args ...object.Object) object.Object {
 
 	switch arg := args[0].(type) {
 	case *object.String:
-		return &object.Integer{Value: int64(len(arg.Value))}
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 	case *object.Array:
 		return &object.Integer{Value: int64(len(arg.Elements))}
 	default:
@@ -26,9 +108,99 @@ func builtInLen(args ...object.Object) object.Object {
 }
 
 func puts(args ...object.Object) object.Object {
+	return writeLines(os.Stdout, args)
+}
+
+func eputs(args ...object.Object) object.Object {
+	return writeLines(os.Stderr, args)
+}
+
+// Printer returns a builtin that behaves like puts but writes to w.
+func Printer(w io.Writer) *object.BuiltIn {
+	return &object.BuiltIn{Fn: func(args ...object.Object) object.Object {
+		return writeLines(w, args)
+	}}
+}
+
+func writeLines(w io.Writer, args []object.Object) object.Object {
 	for _, arg := range args {
-		fmt.Println(arg.Inspect())
+		fmt.Fprintln(w, arg.Inspect())
 	}
 
 	return NULL
 }
+
+// builtInInt converts to an integer. Floats are truncated toward zero and
+// strings are read as decimal, so a leading zero does not mean octal.
+func builtInInt(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt:
+		return arg
+	case *object.Float:
+		if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+			return newError("cannot convert %s to INTEGER", arg.Inspect())
+		}
+		if arg.Value >= math.MaxInt64 || arg.Value < math.MinInt64 {
+			n, _ := big.NewFloat(arg.Value).Int(nil)
+			return newInteger(n)
+		}
+		return &object.Integer{Value: int64(arg.Value)}
+	case *object.String:
+		value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10)
+		if !ok {
+			return newError("cannot convert %q to INTEGER", arg.Value)
+		}
+		return newInteger(value)
+	case *object.Boolean:
+		if arg.Value {
+			return &object.Integer{Value: 1}
+		}
+		return &object.Integer{Value: 0}
+	default:
+		return newError("argument to `int` not supported, got %s", args[0].Type())
+	}
+}
+
+func builtInFloat(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Float:
+		return arg
+	case *object.Integer, *object.BigInt:
+		return &object.Float{Value: toFloat(arg)}
+	case *object.String:
+		value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+		if err != nil {
+			return newError("cannot convert %q to FLOAT", arg.Value)
+		}
+		return &object.Float{Value: value}
+	default:
+		return newError("argument to `float` not supported, got %s", args[0].Type())
+	}
+}
+
+// builtInError makes an error value from a message and optional data, to be
+// thrown later.
+func builtInError(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	message, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `error` must be STRING, got %s", args[0].Type())
+	}
+
+	err := &object.Error{Message: message.Value}
+	if len(args) == 2 {
+		err.Data = args[1]
+	}
+	return &object.ErrorValue{Error: err}
+}
@@ -0,0 +1,152 @@
+package evaluator
+
+import (
+	"cmp"
+	"interpreter/object"
+	"strings"
+)
+
+// visited holds the pairs of arrays and hashes being compared further up,
+// so that comparing values that contain themselves terminates. A pair met
+// again is treated as equal: any difference shows up elsewhere.
+type visited map[[2]object.Object]bool
+
+func (v *visited) enter(left, right object.Object) bool {
+	if *v == nil {
+		*v = visited{}
+	}
+	pair := [2]object.Object{left, right}
+	if (*v)[pair] {
+		return false
+	}
+	(*v)[pair] = true
+	return true
+}
+
+// objectsEqual implements ==. Numbers are equal if their values are, so
+// 1 == 1.0; strings, arrays and hashes are equal if their contents are,
+// hashes regardless of key order; functions and other values are equal
+// only to themselves. Values of different types are never equal.
+func objectsEqual(left, right object.Object) bool {
+	return equal(left, right, nil)
+}
+
+func equal(left, right object.Object, seen visited) bool {
+	switch l := left.(type) {
+	case *object.Integer, *object.BigInt:
+		if right.Type() == object.INTEGER_OBJ {
+			return compareIntegers(left, right) == 0
+		}
+		return isNumber(right) && toFloat(left) == toFloat(right)
+	case *object.Float:
+		return isNumber(right) && l.Value == toFloat(right)
+	case *object.String:
+		r, ok := right.(*object.String)
+		return ok && l.Value == r.Value
+	case *object.Boolean:
+		r, ok := right.(*object.Boolean)
+		return ok && l.Value == r.Value
+	case *object.Null:
+		_, ok := right.(*object.Null)
+		return ok
+	case *object.Array:
+		r, ok := right.(*object.Array)
+		if !ok || len(l.Elements) != len(r.Elements) {
+			return false
+		}
+		if l == r || !seen.enter(l, r) {
+			return true
+		}
+		for i, element := range l.Elements {
+			if !equal(element, r.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		r, ok := right.(*object.Hash)
+		if !ok || l.Len() != r.Len() {
+			return false
+		}
+		if l == r || !seen.enter(l, r) {
+			return true
+		}
+		for _, pair := range l.Ordered() {
+			value, ok := r.Get(pair.Key.(object.Hashable))
+			if !ok || !equal(pair.Value, value, seen) {
+				return false
+			}
+		}
+		return true
+	default:
+		return left == right
+	}
+}
+
+// evalOrderingExpression implements <, >, <= and >= on strings and
+// arrays. Strings compare by their bytes, which for UTF-8 is the order of
+// their code points. Arrays compare element by element, and a prefix comes
+// before the longer array.
+func evalOrderingExpression(operator string, left, right object.Object) object.Object {
+	order, err := compareObjects(operator, left, right, nil)
+	if err != nil {
+		return err
+	}
+
+	switch operator {
+	case "<":
+		return nativeBoolToBooleanObject(order < 0)
+	case ">":
+		return nativeBoolToBooleanObject(order > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(order <= 0)
+	default:
+		return nativeBoolToBooleanObject(order >= 0)
+	}
+}
+
+func isOrdering(operator string) bool {
+	return operator == "<" || operator == ">" || operator == "<=" || operator == ">="
+}
+
+// compareObjects returns -1, 0 or 1 as left orders before, with or after
+// right. operator is only used to describe values that cannot be ordered.
+func compareObjects(operator string, left, right object.Object, seen visited) (int, *object.Error) {
+	switch l := left.(type) {
+	case *object.Integer, *object.BigInt:
+		if right.Type() == object.INTEGER_OBJ {
+			return compareIntegers(left, right), nil
+		}
+		if isNumber(right) {
+			return cmp.Compare(toFloat(left), toFloat(right)), nil
+		}
+	case *object.Float:
+		if isNumber(right) {
+			return cmp.Compare(l.Value, toFloat(right)), nil
+		}
+	case *object.String:
+		if r, ok := right.(*object.String); ok {
+			return strings.Compare(l.Value, r.Value), nil
+		}
+	case *object.Array:
+		r, ok := right.(*object.Array)
+		if !ok {
+			break
+		}
+		if l == r || !seen.enter(l, r) {
+			return 0, nil
+		}
+		for i := 0; i < len(l.Elements) && i < len(r.Elements); i++ {
+			order, err := compareObjects(operator, l.Elements[i], r.Elements[i], seen)
+			if err != nil || order != 0 {
+				return order, err
+			}
+		}
+		return cmp.Compare(len(l.Elements), len(r.Elements)), nil
+	}
+
+	if left.Type() != right.Type() {
+		return 0, newError("Type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
+	return 0, newError("Unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
@@ -4,15 +4,37 @@ import (
 	"fmt"
 	"interpreter/ast"
 	"interpreter/object"
+	"math"
+	"sort"
+	"strings"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+	if err := env.Meter().Step(); err != nil {
+		result = err
+	} else {
+		result = evalNode(node, env)
+	}
+
+	// Errors are created without a position; the innermost node that
+	// produced one is where it gets reported.
+	if err, ok := result.(*object.Error); ok && !err.Position.IsValid() {
+		err.Position = node.Pos()
+	}
+
+	return result
+}
+
+func evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
@@ -28,9 +50,30 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
 		env.Set(node.Name.Value, val)
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return throw(val)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
 
 	// Expressions
 	case *ast.Identifier:
@@ -39,6 +82,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
 	case *ast.PrefixExpression:
 		operand := Eval(node.Operand, env)
 		if isError(operand) {
@@ -50,31 +95,51 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(left) {
 			return left
 		}
+		if isLogical(node.Operator) {
+			return evalLogicalExpression(node, left, env)
+		}
 
 		right := Eval(node.RightOperand, env)
 		if isError(right) {
 			return right
 		}
 
-		return evalInfixExpression(node.Operator, left, right)
+		return allocate(env, evalInfixExpression(node.Operator, left, right))
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
 	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return allocate(env, &object.Function{
+			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Env:        env,
+			Body:       node.Body,
+		})
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
 
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		args, named, err := evalArguments(node.Arguments, env)
+		if err != nil {
+			return err
 		}
 
-		return applyFunction(function, args)
+		if fn, ok := function.(*object.Function); ok && node.Tail {
+			return &tailCall{function: fn, args: args, named: named, call: node}
+		}
+		if _, ok := function.(*object.BuiltIn); ok {
+			return allocate(env, placeCallbackFrames(applyFunction(env.Meter(), function, args, named), node))
+		}
+		return addStackFrame(applyFunction(env.Meter(), function, args, named), function, node)
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInt{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.Boolean:
@@ -84,7 +149,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return allocate(env, &object.Array{Elements: elements})
 	case *ast.IndexExpression:
 		collection := Eval(node.Collection, env)
 		if isError(collection) {
@@ -96,39 +161,305 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalIndexExpression(collection, index)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return allocate(env, evalHashLiteral(node, env))
+	case *ast.ImportExpression:
+		return evalImport(node.Path.Value, node.Pos(), env)
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
 	}
 
 	return nil
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// allocate charges a newly created object to the program's allocation
+// budget. Errors pass through uncharged.
+func allocate(env *object.Environment, obj object.Object) object.Object {
+	if isError(obj) {
+		return obj
+	}
+	if err := env.Meter().Allocate(object.SizeOf(obj)); err != nil {
+		return err
+	}
+	return obj
+}
+
+// addStackFrame records a failed call of a script function in the error's
+// stack trace.
+func addStackFrame(result, function object.Object, call *ast.CallExpression) object.Object {
+	err, ok := result.(*object.Error)
+	if !ok {
+		return result
+	}
+	if fn, ok := function.(*object.Function); ok {
+		err.Stack = append(err.Stack, object.StackFrame{Function: fn.Name, Position: call.Pos()})
+	}
+	return err
+}
+
+// applyFunction calls fn. A builtin accounts for what it creates with meter;
+// a script function uses the meter of its environment.
+func applyFunction(meter *object.Meter, fn object.Object, args []object.Object, named []namedArgument) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		meter := fn.Env.Meter()
+		if err := meter.Enter(); err != nil {
+			return err
+		}
+		defer meter.Leave()
+
+		// A call in tail position comes back as a tailCall for this loop to
+		// make, so that tail recursion runs in constant space and counts
+		// against MaxTailCalls rather than the call depth.
+		var tailCalls tailFrames
+		for {
+			result := evalFunctionBody(fn, args, named)
+			next, ok := result.(*tailCall)
+			if !ok {
+				return tailCalls.addTo(result)
+			}
+			frame := object.StackFrame{Function: next.function.Name, Position: next.call.Pos()}
+			err := tailCalls.push(meter, frame)
+			if err == nil {
+				err = meter.TailCall(tailCalls.count)
+			}
+			if err != nil {
+				err.Position = frame.Position
+				return tailCalls.addTo(err)
+			}
+			fn, args, named = next.function, next.args, next.named
+		}
 	case *object.BuiltIn:
-		return fn.Fn(args...)
+		if len(named) > 0 {
+			return newError("builtin functions do not take named arguments, got %s", named[0].name)
+		}
+		return fn.Call(caller(meter), meter, args...)
 	}
 
 	return newError("Object is not a function, got %s instead.", fn.Type())
 
 }
 
+func evalFunctionBody(fn *object.Function, args []object.Object, named []namedArgument) object.Object {
+	if err := fn.Env.Meter().Allocate(1 + int64(len(args)+len(named))); err != nil {
+		return err
+	}
+	extendedEnv, err := extendFunctionEnv(fn, args, named)
+	if err != nil {
+		return err
+	}
+	evaluated := Eval(fn.Body, extendedEnv)
+	if isLoopSignal(evaluated) {
+		return newError("%s outside of a loop", evaluated.Inspect())
+	}
+	return unwrapReturnValue(evaluated)
+}
+
+// tailCall is what a call in tail position evaluates to. Rather than call
+// the function, which would nest one more Eval for each iteration of a
+// recursive loop, it leaves the call to applyFunction.
+type tailCall struct {
+	function *object.Function
+	args     []object.Object
+	named    []namedArgument
+	call     *ast.CallExpression
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call to " + functionName(tc.function.Name) }
+
+// maxTailFrames is how many of the innermost tail calls of a chain are kept
+// for stack traces.
+const maxTailFrames = 100
+
+// tailFrames keeps the frames of the innermost tail calls made in place of
+// one call, in a ring, and counts the rest.
+type tailFrames struct {
+	frames []object.StackFrame
+	count  int
+}
+
+func (t *tailFrames) push(meter *object.Meter, frame object.StackFrame) *object.Error {
+	if len(t.frames) < maxTailFrames {
+		if err := meter.Allocate(1); err != nil {
+			return err
+		}
+		t.frames = append(t.frames, frame)
+	} else {
+		t.frames[t.count%maxTailFrames] = frame
+	}
+	t.count++
+	return nil
+}
+
+// addTo gives an error from a function reached by tail calls the position
+// and stack frames that ordinary calls would have given it, up to
+// maxTailFrames of them.
+func (t *tailFrames) addTo(result object.Object) object.Object {
+	err, ok := result.(*object.Error)
+	if !ok || t.count == 0 {
+		return result
+	}
+	if !err.Position.IsValid() {
+		err.Position = t.frames[(t.count-1)%maxTailFrames].Position
+	}
+	for i := 1; i <= len(t.frames); i++ {
+		err.Stack = append(err.Stack, t.frames[(t.count-i)%maxTailFrames])
+	}
+	err.Stack[len(err.Stack)-1].Elided = t.count - len(t.frames)
+	return err
+}
+
+// caller returns the Caller given to higher-order builtins. A script
+// function that fails gets a frame in the stack trace; its position is the
+// call of the builtin, filled in by placeCallbackFrames.
+func caller(meter *object.Meter) object.Caller {
+	return func(fn object.Object, args ...object.Object) object.Object {
+		result := applyFunction(meter, fn, args, nil)
+		if err, ok := result.(*object.Error); ok {
+			if fn, ok := fn.(*object.Function); ok {
+				err.Stack = append(err.Stack, object.StackFrame{Function: fn.Name})
+			}
+		}
+		return result
+	}
+}
+
+func placeCallbackFrames(result object.Object, call *ast.CallExpression) object.Object {
+	if err, ok := result.(*object.Error); ok {
+		for i := range err.Stack {
+			if !err.Stack[i].Position.IsValid() {
+				err.Stack[i].Position = call.Pos()
+			}
+		}
+	}
+	return result
+}
+
+// unwrapReturnValue gives the value of a function body. A body that is
+// empty or ends in a let statement has no value, and gives NULL.
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
-		return returnValue.Value
+		obj = returnValue.Value
+	}
+	if obj == nil {
+		return NULL
 	}
 	return obj
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv binds the arguments of a call to the parameters of fn.
+// Positional arguments fill the parameters in order and any surplus goes to
+// the rest parameter; named arguments fill parameters by name. Parameters
+// left over take their default values, which are evaluated in the new
+// environment so that they can refer to the parameters before them.
+func extendFunctionEnv(fn *object.Function, args []object.Object, named []namedArgument) (*object.Environment, *object.Error) {
+	required := len(fn.Parameters)
+	for required > 0 && fn.Default(required-1) != nil {
+		required--
+	}
+	maximum := len(fn.Parameters)
+	if fn.Rest != nil {
+		maximum = -1
+	}
+	if (len(args) > len(fn.Parameters) && fn.Rest == nil) ||
+		(len(named) == 0 && len(args) < required) {
+		return nil, arityError(fn.Name, required, maximum, len(args)+len(named))
+	}
+
+	values := make([]object.Object, len(fn.Parameters))
+	copy(values, args)
+	for _, argument := range named {
+		i := parameterIndex(fn.Parameters, argument.name)
+		switch {
+		case i < 0:
+			return nil, newError("%s has no parameter %s", functionName(fn.Name), argument.name)
+		case values[i] != nil:
+			return nil, newError("argument %s to %s given twice", argument.name, functionName(fn.Name))
+		}
+		values[i] = argument.value
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for i, param := range fn.Parameters {
-		env.Set(param.Value, args[i])
+		value := values[i]
+		if value == nil {
+			if fn.Default(i) == nil {
+				return nil, newError("missing argument %s to %s", param.Value, functionName(fn.Name))
+			}
+			value = Eval(fn.Default(i), env)
+			if err, ok := value.(*object.Error); ok {
+				return nil, err
+			}
+		}
+		env.Set(param.Value, value)
+	}
+	if fn.Rest != nil {
+		var rest []object.Object
+		if len(args) > len(fn.Parameters) {
+			rest = args[len(fn.Parameters):]
+		}
+		env.Set(fn.Rest.Value, newArray(rest))
+	}
+	return env, nil
+}
+
+func parameterIndex(parameters []*ast.Identifier, name string) int {
+	for i, param := range parameters {
+		if param.Value == name {
+			return i
+		}
 	}
-	return env
+	return -1
+}
+
+// arityError reports a call with the wrong number of arguments. max is -1
+// for functions that take any number beyond min.
+func arityError(name string, min, max, got int) *object.Error {
+	return newError("wrong number of arguments to %s: want=%s, got=%d", functionName(name), arity(min, max), got)
+}
+
+func functionName(name string) string {
+	return object.StackFrame{Function: name}.Name()
+}
+
+// namedArgument is an argument passed as name: value.
+type namedArgument struct {
+	name  string
+	value object.Object
+}
+
+// evalArguments evaluates the arguments of a call, spreading the elements
+// of ...array arguments and collecting name: value arguments separately.
+func evalArguments(exps []ast.Expression, env *object.Environment) ([]object.Object, []namedArgument, object.Object) {
+	args := []object.Object{}
+	var named []namedArgument
+	for _, e := range exps {
+		switch e := e.(type) {
+		case *ast.SpreadExpression:
+			value := Eval(e.Value, env)
+			if isError(value) {
+				return nil, nil, value
+			}
+			array, ok := value.(*object.Array)
+			if !ok {
+				return nil, nil, newError("cannot spread %s, want ARRAY", value.Type())
+			}
+			args = append(args, array.Elements...)
+		case *ast.NamedArgument:
+			value := Eval(e.Value, env)
+			if isError(value) {
+				return nil, nil, value
+			}
+			named = append(named, namedArgument{name: e.Name.Value, value: value})
+		default:
+			value := Eval(e, env)
+			if isError(value) {
+				return nil, nil, value
+			}
+			args = append(args, value)
+		}
+	}
+	return args, named, nil
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -161,6 +492,8 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 			return result.Value
 		case *object.Error:
 			return result
+		case *object.Break, *object.Continue:
+			return newError("%s outside of a loop", result.Inspect())
 		}
 	}
 
@@ -186,15 +519,157 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
 
-		shouldEarlyReturn := result != nil && result.Type() == object.RETURN_VALUE_OBJ || result.Type() == object.ERROR_OBJ
-		if shouldEarlyReturn {
+		if result == nil {
+			continue
+		}
+
+		switch result.Type() {
+		case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+			return result
+		}
+	}
+
+	return result
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := Eval(ws.Body, env)
+		if result, stop := loopControl(result); stop {
+			return result
+		}
+	}
+}
+
+// evalForStatement runs the body once per element of an array, character of
+// a string or pair of a hash. With one loop variable it is bound to the
+// element, character or [key, value] pair; with two the first is bound to the
+// index (or key) and the second to the element (or value). Each iteration
+// gets its own scope so closures capture that iteration's values.
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	var keys, values []object.Object
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		for i, element := range iterable.Elements {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+			values = append(values, element)
+		}
+	case *object.String:
+		for i, char := range []rune(iterable.Value) {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+			values = append(values, &object.String{Value: string(char)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.Ordered() {
+			keys = append(keys, pair.Key)
+			values = append(values, pair.Value)
+		}
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	for i := range values {
+		iterationEnv := object.NewEnclosedEnvironment(env)
+
+		if fs.Key != nil {
+			iterationEnv.Set(fs.Key.Value, keys[i])
+			iterationEnv.Set(fs.Value.Value, values[i])
+		} else if iterable.Type() == object.HASH_OBJ {
+			pair := &object.Array{Elements: []object.Object{keys[i], values[i]}}
+			iterationEnv.Set(fs.Value.Value, pair)
+		} else {
+			iterationEnv.Set(fs.Value.Value, values[i])
+		}
+
+		result := Eval(fs.Body, iterationEnv)
+		if result, stop := loopControl(result); stop {
+			return result
+		}
+	}
+
+	return NULL
+}
+
+// loopControl interprets the result of one loop iteration. It reports whether
+// the loop has to stop and, if so, what the loop statement evaluates to.
+func loopControl(result object.Object) (object.Object, bool) {
+	if result == nil {
+		return nil, false
+	}
+
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return NULL, true
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+func isLoopSignal(obj object.Object) bool {
+	return obj == BREAK || obj == CONTINUE
+}
+
+// evalTryExpression evaluates to the value of the try block, or of the
+// catch block if the try block failed. The finally block runs in every case
+// and only changes the outcome if it fails, returns or leaves a loop itself.
+// evalTryExpression runs the catch clause for ordinary errors only: an
+// error from a limit or a canceled run passes through, after finally.
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(node.Block, env)
+
+	err, failed := result.(*object.Error)
+	if failed && err.Kind.Catchable() && node.Catch != nil {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		catchEnv.Set(node.Parameter.Value, &object.ErrorValue{Error: err})
+		result = Eval(node.Catch, catchEnv)
+	}
+
+	if node.Finally != nil {
+		finally := Eval(node.Finally, env)
+		if failed && !err.Kind.Catchable() {
 			return result
 		}
+		if isError(finally) || isLoopSignal(finally) || finally != nil && finally.Type() == object.RETURN_VALUE_OBJ {
+			return finally
+		}
 	}
 
 	return result
 }
 
+// throw raises value. Throwing a caught error raises it again with its
+// original position and the calls it has already passed through; any other
+// value becomes the data of a new error.
+func throw(value object.Object) *object.Error {
+	if errValue, ok := value.(*object.ErrorValue); ok {
+		err := *errValue.Error
+		err.Stack = append([]object.StackFrame(nil), err.Stack...)
+		return &err
+	}
+
+	message := value.Inspect()
+	if str, ok := value.(*object.String); ok {
+		message = str.Value
+	}
+	return &object.Error{Message: message, Data: value}
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
@@ -209,14 +684,14 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+// isTruthy goes by a boolean's value rather than its identity, as host
+// builtins may create booleans other than TRUE and FALSE.
 func isTruthy(obj object.Object) bool {
-	switch obj {
-	case NULL:
-		return false
-	case TRUE:
-		return true
-	case FALSE:
+	switch obj := obj.(type) {
+	case *object.Null:
 		return false
+	case *object.Boolean:
+		return obj.Value
 	default:
 		return true
 	}
@@ -234,39 +709,37 @@ func evalPrefixExpression(operator string, operand object.Object) object.Object
 }
 
 func evalBangOperatorExpression(operand object.Object) object.Object {
-	switch operand {
-	case TRUE:
-		return FALSE
-	case FALSE:
-		return TRUE
-	case NULL:
-		return TRUE
-	default:
-		return FALSE
-	}
+	return nativeBoolToBooleanObject(!isTruthy(operand))
 }
 
 func evalMinusPrefixOperatorExpression(operand object.Object) object.Object {
-	if operand.Type() != object.INTEGER_OBJ {
+	switch operand := operand.(type) {
+	case *object.Integer, *object.BigInt:
+		return negateInteger(operand)
+	case *object.Float:
+		return &object.Float{Value: -operand.Value}
+	default:
 		return newError("Unknown operator: -%s", operand.Type())
 	}
-
-	value := operand.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
+	case operator == "==":
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
+	case operator == "!=":
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
+	case isOrdering(operator) && left.Type() == right.Type() &&
+		(left.Type() == object.STRING_OBJ || left.Type() == object.ARRAY_OBJ):
+		return evalOrderingExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ:
 		return evalArrayInfixExpression(operator, left, right)
-	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
-	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
 		return newError("Type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
@@ -274,32 +747,73 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+// evalFloatInfixExpression handles arithmetic where at least one operand is a
+// float. Integers are promoted to floats, so the result is always a float.
+// Division by zero follows IEEE 754 and gives an infinity or NaN.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return &object.Float{Value: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return &object.Float{Value: leftVal - rightVal}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return &object.Float{Value: leftVal * rightVal}
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "div":
+		return &object.Float{Value: math.Floor(leftVal / rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
-	case "==":
-		return nativeBoolToBooleanObject(leftVal == rightVal)
-	case "!=":
-		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	default:
 		return newError("Unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
+// evalLogicalExpression evaluates the right operand of && and || only when
+// the left one does not decide the result, and returns whichever operand
+// did: a missing value || "none" is "none", and false && f() is false
+// without calling f.
+func evalLogicalExpression(node *ast.InfixExpression, left object.Object, env *object.Environment) object.Object {
+	if isTruthy(left) == (node.Operator == "||") {
+		return left
+	}
+	return Eval(node.RightOperand, env)
+}
+
+func isLogical(operator string) bool {
+	return operator == "&&" || operator == "||"
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		return bigToFloat(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	if operator != "<>" {
 		return newError("Unknown operator: %s %s %s", left.Type(), operator, right.Type())
@@ -319,24 +833,173 @@ func evalArrayInfixExpression(operator string, left, right object.Object) object
 	leftArray := left.(*object.Array).Elements
 	rightArray := right.(*object.Array).Elements
 
-	return &object.Array{Elements: append(leftArray, rightArray...)}
+	// The result always gets its own backing array: appending in place would
+	// let two concatenations onto the same array overwrite each other.
+	return newArray(leftArray, rightArray...)
+}
+
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if node.Operator != "=" {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		if current != nil {
+			value = evalCompoundOperator(node.Operator, current, value)
+			if isError(value) {
+				return value
+			}
+		}
+
+		if _, ok := env.Assign(target.Value, value); !ok {
+			return newError("cannot assign to undefined variable %s", target.Value)
+		}
+		return value
+	case *ast.IndexExpression:
+		collection := Eval(target.Collection, env)
+		if isError(collection) {
+			return collection
+		}
+
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		var current object.Object
+		if node.Operator != "=" {
+			current = evalIndexExpression(collection, index)
+			if isError(current) {
+				return current
+			}
+		}
+
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		if current != nil {
+			value = evalCompoundOperator(node.Operator, current, value)
+			if isError(value) {
+				return value
+			}
+		}
+
+		return evalIndexAssignment(collection, index, value)
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+// evalCompoundOperator applies the operator of a compound assignment such as
+// += to the current value of the target and the assigned value.
+func evalCompoundOperator(operator string, current, value object.Object) object.Object {
+	return evalInfixExpression(strings.TrimSuffix(operator, "="), current, value)
+}
+
+// evalIndexAssignment updates an array element or hash entry in place, so
+// every binding that refers to the same array or hash sees the change.
+func evalIndexAssignment(collection, index, value object.Object) object.Object {
+	switch collection := collection.(type) {
+	case *object.Array:
+		if index.Type() != object.INTEGER_OBJ {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		i, ok := index.(*object.Integer)
+		if !ok || i.Value < 0 || i.Value >= int64(len(collection.Elements)) {
+			return newError("index out of range: %s (length %d)", index.Inspect(), len(collection.Elements))
+		}
+		collection.Elements[i.Value] = value
+		return value
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		collection.Set(key, value)
+		return value
+	default:
+		return newError("index assignment not supported %s", collection.Type())
+	}
 }
 
 func evalIndexExpression(collection, index object.Object) object.Object {
 	switch {
 	case collection.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(collection, index)
+	case collection.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(collection, index)
 	case collection.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(collection, index)
+	case collection.Type() == object.ERROR_VALUE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalErrorField(collection.(*object.ErrorValue).Error, index.(*object.String).Value)
 	default:
 		return newError("index operator not supported %s", collection.Type())
 	}
 }
 
+// evalErrorField gives scripts access to an error's message, kind, data,
+// position and stack. The stack is an array of hashes with the keys
+// "function" and "position", innermost call first.
+func evalErrorField(err *object.Error, field string) object.Object {
+	switch field {
+	case "message":
+		return &object.String{Value: err.Message}
+	case "data":
+		if err.Data == nil {
+			return NULL
+		}
+		return err.Data
+	case "kind":
+		if err.Kind == "" {
+			return NULL
+		}
+		return &object.String{Value: string(err.Kind)}
+	case "position":
+		return &object.String{Value: err.Position.String()}
+	case "stack":
+		frames := make([]object.Object, 0, len(err.Stack))
+		for _, frame := range err.Stack {
+			frames = append(frames, stringHash(map[string]string{
+				"function": frame.Name(),
+				"position": frame.Position.String(),
+			}))
+		}
+		return &object.Array{Elements: frames}
+	default:
+		return NULL
+	}
+}
+
+func stringHash(values map[string]string) *object.Hash {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := object.NewHash()
+	for _, key := range keys {
+		hash.Set(&object.String{Value: key}, &object.String{Value: values[key]})
+	}
+	return hash
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := map[object.HashKey]object.HashPair{}
+	hash := object.NewHash()
 
-	for k, v := range node.Pairs {
+	for _, k := range node.Keys {
 		key := Eval(k, env)
 		if isError(key) {
 			return key
@@ -347,16 +1010,15 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(v, env)
+		value := Eval(node.Pairs[k], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
@@ -367,24 +1029,22 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 }
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	a := array.(*object.Array)
-	i := index.(*object.Integer).Value
-	max := int64(len(a.Elements) - 1)
-
-	if i < 0 || i > max {
+	i, ok := index.(*object.Integer)
+	if !ok || i.Value < 0 || i.Value >= int64(len(a.Elements)) {
 		return NULL
 	}
 
-	return a.Elements[i]
+	return a.Elements[i.Value]
 }
 
 func newError(format string, a ...interface{}) *object.Error {
@@ -1,10 +1,17 @@
 package evaluator
 
 import (
+	"context"
+	"fmt"
 	"interpreter/lexer"
 	"interpreter/object"
 	"interpreter/parser"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -27,6 +34,31 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 / 2", 3},
+		{"-7 / 2", -3},
+		{"7 / -2", -3},
+		{"7 % -2", 1},
+		{"7 div 2", 3},
+		{"-7 div 2", -4},
+		{"7 div -2", -4},
+		{"-7 div -2", 3},
+		{"-8 div 2", -4},
+		{"1 + 7 div 2 * 2", 7},
+		{"10 - 7 % 4 * 2", 4},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"5 ** 0", 1},
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ^ 10", 6},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"1 | 2 ^ 3 & 1", 2},
+		{"1 + 1 << 2", 5},
 	}
 
 	for _, tt := range tests {
@@ -35,6 +67,168 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"9223372036854775807 * 2", "18446744073709551614"},
+		{"-9223372036854775807 - 1", "-9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"2 ** 64", "18446744073709551616"},
+		{"3 ** 40", "12157665459056928801"},
+		{"1 << 70", "1180591620717411303424"},
+		{"-1 << 63", "-9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"-123456789012345678901234567890", "-123456789012345678901234567890"},
+		{"18446744073709551616 - 1", "18446744073709551615"},
+		{"(2 ** 64 + 1) - 2 ** 64", "1"},
+		{"(2 ** 64) / (2 ** 32)", "4294967296"},
+		{"(2 ** 64 + 5) % 10", "1"},
+		{"-(2 ** 64) / 10", "-1844674407370955161"},
+		{"-(2 ** 64) div 10", "-1844674407370955162"},
+		{"(2 ** 64) div -(2 ** 63) ", "-2"},
+		{"(2 ** 64 + 1) div -(2 ** 63)", "-3"},
+		{"(-9223372036854775807 - 1) % -1", "0"},
+		{"(-9223372036854775807 - 1) div -1", "9223372036854775808"},
+		{"-(2 ** 64) % 10", "-6"},
+		{"(2 ** 64 - 1) & 255", "255"},
+		{"(1 << 70) >> 68", "4"},
+		{"(-(1 << 70)) >> 100", "-1"},
+		{"2 ** 64 > 2 ** 63", "true"},
+		{"2 ** 64 > 1", "true"},
+		{"2 ** 64 == 18446744073709551616", "true"},
+		{"2 ** 64 == 2 ** 64 + 0", "true"},
+		{"2 ** 63 == 9223372036854775807", "false"},
+		{"2 ** 64 == 18446744073709551616.0", "true"},
+		{"2 ** 64 + 0.5", "1.8446744073709552e+19"},
+		{"2 ** -64 > 0", "true"},
+		{`{2 ** 64: "big"}[18446744073709551616]`, "big"},
+		{"[1, 2][2 ** 64]", "null"},
+		{"sort([2 ** 64, 1, -(2 ** 70)])", "[-1180591620717411303424, 1, 18446744073709551616]"},
+		{`int("123456789012345678901234567890")`, "123456789012345678901234567890"},
+		{"int(1e20)", "100000000000000000000"},
+		{"float(2 ** 64)", "1.8446744073709552e+19"},
+		{`format("%d", 2 ** 70)`, "1180591620717411303424"},
+		{"let f = fn(n) { if (n < 2) { 1 } else { n * f(n - 1) } }; f(25)", "15511210043330985984000000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || isError(evaluated) || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestBigIntegerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(2 ** 64) / 0", "division by zero: 18446744073709551616 / 0"},
+		{"(2 ** 64) % 0", "division by zero: 18446744073709551616 % 0"},
+		{"(2 ** 64) div 0", "division by zero: 18446744073709551616 div 0"},
+		{"2 ** (2 ** 64)", "integer too large: 2 ** 18446744073709551616 exceeds 16777216 bits"},
+		{"10 ** 100000000", "integer too large: 10 ** 100000000 exceeds 16777216 bits"},
+		{"1 << 100000000", "integer too large: 1 << 100000000 exceeds 16777216 bits"},
+		{"1 << -1", "negative shift count: -1"},
+		{"let a = [1]; a[2 ** 64] = 1", "index out of range: 18446744073709551616 (length 1)"},
+		{`repeat("a", 2 ** 64)`, "argument 2 to `repeat` out of range: 18446744073709551616"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1e3", 1000},
+		{"0.1 + 0.2 * 2", 0.5},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"7 / 2.0", 3.5},
+		{"10 * 0.25", 2.5},
+		{"float(7) / 2", 3.5},
+		{"float(\"2.5\")", 2.5},
+		{"7.5 % 2", 1.5},
+		{"-7.5 div 2", -4},
+		{"7 div 2.0", 3},
+		{"2 ** -1", 0.5},
+		{"4 ** 0.5", 2},
+		{"1.5 ** 2", 2.25},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestNumericPromotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"7 / 2", 3},
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{"int(\"42\")", 42},
+		{"int(\"010\")", 10},
+		{"int(\" -7 \")", -7},
+		{"int(true)", 1},
+		{"1 < 1.5", true},
+		{"2.0 == 2", true},
+		{"2.5 > 3", false},
+		{"1.5 != 1.5", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"1.5 * 2", "3.0"},
+		{"0.1", "0.1"},
+		{"1e21", "1e+21"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
@@ -87,6 +281,14 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 2", false},
+		{"1.5 >= 1", true},
+		{"1 == 1.0", true},
+		{"1 == \"1\"", false},
+		{"1 != \"1\"", true},
+		{"let nan = 0.0 / 0.0; nan == nan", false},
 	}
 
 	for _, tt := range tests {
@@ -95,6 +297,122 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"false && true || true", true},
+		{"true || false && false", true},
+		{"1 && 2", 2},
+		{"false || 3", 3},
+		{"1 || 2", 1},
+		{"let x = if (false) { 1 }; x || 5", 5},
+		{"let n = 0; let bump = fn() { n += 1; true }; false && bump(); true || bump(); n", 0},
+		{"let n = 0; let bump = fn() { n += 1; true }; true && bump(); false || bump(); n", 2},
+		{"true || missing", true},
+		{"false && missing", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, 2] == [1, 2.0]`, true},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, 2] == [1, 2, 3]`, false},
+		{`[[1, "x"], {"a": [2]}] == [[1, "x"], {"a": [2]}]`, true},
+		{`{"a": 1, "b": 2} == {"b": 2, "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{"a": 1} != {"a": 1, "b": 2}`, true},
+		{`{1: "x"} == {1.0: "x"}`, false},
+		{`if (false) { 1 } == if (false) { 2 }`, true},
+		{`[] == {}`, false},
+		{`let f = fn() { 1 }; f == f`, true},
+		{`fn() { 1 } == fn() { 1 }`, false},
+		{`let a = [1]; a[0] = a; let b = [1]; b[0] = b; a == b`, true},
+		{`let a = [1, 2]; a[0] = a; let b = [1, 3]; b[0] = b; a == b`, false},
+		{`let h = {}; h["self"] = h; h == h`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testBooleanObject(t, evaluated, tt.expected) {
+			t.Errorf("input: %s", tt.input)
+		}
+	}
+}
+
+func TestOrdering(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"apple" < "banana"`, true},
+		{`"apple" < "app"`, false},
+		{`"app" < "apple"`, true},
+		{`"b" > "a"`, true},
+		{`"a" <= "a"`, true},
+		{`"a" >= "b"`, false},
+		{`"Z" < "a"`, true},
+		{`"é" > "z"`, true},
+		{`[1, 2] < [1, 3]`, true},
+		{`[1, 2] < [1, 2, 0]`, true},
+		{`[2] > [1, 9]`, true},
+		{`[1, 2] <= [1, 2]`, true},
+		{`[[1, "b"]] < [[1, "c"]]`, true},
+		{`[] >= []`, true},
+		{`[1] < ["a"]`, "Type mismatch: INTEGER < STRING"},
+		{`[true] < [false]`, "Unknown operator: BOOLEAN < BOOLEAN"},
+		{`"a" < 1`, "Type mismatch: STRING < INTEGER"},
+		{`{} <= {}`, "Unknown operator: HASH <= HASH"},
+		{`sort([[2, 1], [1, 2], [1]])`, "[[1], [1, 2], [2, 1]]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			if !testBooleanObject(t, evaluated, expected) {
+				t.Errorf("input: %s", tt.input)
+			}
+		case string:
+			if evaluated == nil || (evaluated.Inspect() != expected && !isErrorMessage(evaluated, expected)) {
+				t.Errorf("%s: expected=%q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func isErrorMessage(obj object.Object, message string) bool {
+	err, ok := obj.(*object.Error)
+	return ok && err.Message == message
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -160,6 +478,54 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1; } i;", 5},
+		{"let i = 0; while (false) { let i = i + 1; } i;", 0},
+		{"let i = 0; while (true) { let i = i + 1; if (i > 2) { break; } } i;", 3},
+		{"let i = 0; while (i < 10) { let i = i + 1; if (i < 10) { continue; } return i; }", 10},
+		{"let f = fn(arr) { for (x in arr) { if (x > 1) { return x; } } }; f([1, 5, 7]);", 5},
+		{"let f = fn(arr) { for (i, x in arr) { if (i == 1) { return i * 10 + x; } } }; f([1, 2, 3]);", 12},
+		{"let f = fn() { for (i, c in \"héllo\") { if (i == 1) { return byteLen(c); } } }; f();", 2},
+		{"let x = 1; for (x in [5]) { } x;", 1},
+		{"if (true) { let x = 1; } 5", 5},
+		{"while (false) { 1 }", nil},
+		{"for (x in []) { x }", nil},
+		{"for (x in [1, 2]) { if (x == 1) { continue; } break; }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestForLoopOverHash(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(h) { for (pair in h) { return pair; } }; f({"a": 1});`, "[a, 1]"},
+		{`let f = fn(h) { for (k, v in h) { return [v, k]; } }; f({"a": 1});`, "[1, a]"},
+		{`let f = fn(s) { for (c in s) { return c; } }; f("héllo");`, "h"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -173,6 +539,42 @@ func TestErrorHandling(t *testing.T) {
 			"5 + true; 5;",
 			"Type mismatch: INTEGER + BOOLEAN",
 		},
+		{
+			"5 / 0",
+			"division by zero: 5 / 0",
+		},
+		{
+			"let f = fn() {}; f() + 1",
+			"Type mismatch: NULL + INTEGER",
+		},
+		{
+			"let f = fn() { let x = 1; }; f() + 1",
+			"Type mismatch: NULL + INTEGER",
+		},
+		{
+			"5 % 0",
+			"division by zero: 5 % 0",
+		},
+		{
+			"-5 div 0",
+			"division by zero: -5 div 0",
+		},
+		{
+			"let x = 1; x /= 0",
+			"division by zero: 1 / 0",
+		},
+		{
+			"1 << -1",
+			"negative shift count: -1",
+		},
+		{
+			"1.5 & 1",
+			"Unknown operator: FLOAT & INTEGER",
+		},
+		{
+			"true && missing",
+			"identifier not found: missing",
+		},
 		{
 			"-true",
 			"Unknown operator: -BOOLEAN",
@@ -211,6 +613,70 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"x = 5;",
+			"cannot assign to undefined variable x",
+		},
+		{
+			"let x = 1; x += true;",
+			"Type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"let y = 1; y += z;",
+			"identifier not found: z",
+		},
+		{
+			"let arr = [1]; arr[1] = 2;",
+			"index out of range: 1 (length 1)",
+		},
+		{
+			`let arr = [1]; arr["a"] = 2;`,
+			"array index must be INTEGER, got STRING",
+		},
+		{
+			"let n = 1; n[0] = 2;",
+			"index assignment not supported INTEGER",
+		},
+		{
+			"break;",
+			"break outside of a loop",
+		},
+		{
+			"for (x in [1]) { let f = fn() { continue; }; f(); }",
+			"continue outside of a loop",
+		},
+		{
+			"for (x in 5) { x }",
+			"cannot iterate over INTEGER",
+		},
+		{
+			"while (1 + true) { 1 }",
+			"Type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"1.5 + true",
+			"Type mismatch: FLOAT + BOOLEAN",
+		},
+		{
+			`int("abc")`,
+			`cannot convert "abc" to INTEGER`,
+		},
+		{
+			`int("0x1f")`,
+			`cannot convert "0x1f" to INTEGER`,
+		},
+		{
+			`int("0b11")`,
+			`cannot convert "0b11" to INTEGER`,
+		},
+		{
+			`int("1_000")`,
+			`cannot convert "1_000" to INTEGER`,
+		},
+		{
+			`float([])`,
+			"argument to `float` not supported, got ARRAY",
+		},
 	}
 
 	for _, tt := range tests {
@@ -246,6 +712,48 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = 1; a = 2; a;", 2},
+		{"let a = 1; a = 2;", 2},
+		{"let a = 1; let b = 1; a = b = 5; a + b;", 10},
+		{"let a = 10; a += 5; a -= 3; a *= 2; a /= 4; a;", 6},
+		{"let s = \"ab\"; s <>= \"cd\"; s;", "abcd"},
+		{"let count = 0; let inc = fn() { count = count + 1; }; inc(); inc(); count;", 2},
+		{"let makeCounter = fn() { let n = 0; fn() { n += 1; n } }; let c = makeCounter(); c(); c(); c();", 3},
+		{"let i = 0; while (i < 5) { i += 1; } i;", 5},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x; } sum;", 6},
+		{"let x = 1; let f = fn() { let x = 2; x = 3; x }; f() * 10 + x;", 31},
+		{"let arr = [1, 2, 3]; arr[0] = 5; arr[0] + arr[1];", 7},
+		{"let arr = [1, 2, 3]; arr[2] += 10; arr[2];", 13},
+		{"let arr = [[1], [2]]; arr[1][0] = 9; arr[1][0];", 9},
+		{"let arr = [1]; let alias = arr; alias[0] = 4; arr[0];", 4},
+		{`let h = {"k": 1}; h["k"] = 2; h["k"];`, 2},
+		{`let h = {}; h["new"] = 3; h["new"];`, 3},
+		{`let h = {"n": 1}; h["n"] *= 7; h["n"];`, 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+			}
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
@@ -289,6 +797,100 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(a, b = 2) { [a, b] }; [f(1), f(1, 3)]`, "[[1, 2], [1, 3]]"},
+		{`let f = fn(a, b = a * 10) { a + b }; f(1)`, "11"},
+		{`let n = 0; let f = fn(a = n) { a }; n = 5; f()`, "5"},
+		{`let f = fn(a, b = if (false) { 1 }) { b }; f(1, 2)`, "2"},
+		{`let f = fn(first, ...rest) { [first, rest] }; f(1, 2, 3)`, "[1, [2, 3]]"},
+		{`let f = fn(first, ...rest) { rest }; f(1)`, "[]"},
+		{`let f = fn(a, b = 2, ...rest) { [a, b, rest] }; [f(1), f(1, 3, 4, 5)]`, "[[1, 2, []], [1, 3, [4, 5]]]"},
+		{`let add = fn(a, b, c) { a + b + c }; add(...[1, 2, 3])`, "6"},
+		{`let add = fn(a, b, c) { a + b + c }; let xs = [2]; add(1, ...xs, 3)`, "6"},
+		{`let f = fn(...xs) { xs }; f(...[], ...[1], ...[2, 3])`, "[1, 2, 3]"},
+		{`push(...[[1], 2, 3])`, "[1, 2, 3]"},
+		{`let f = fn(a, b = 2, c = 3) { [a, b, c] }; f(1, c: 30)`, "[1, 2, 30]"},
+		{`let f = fn(a, b) { a - b }; f(b: 1, a: 10)`, "9"},
+		{`let f = fn(a, b = a) { [a, b] }; f(a: 4)`, "[4, 4]"},
+		{`let f = fn(a, b = 2) { a }; f`, "fn(a, b = 2) {\na\n}"},
+		{`map([1, 2], fn(x, ...rest) { rest })`, "[[], []]"},
+		{`map([1, 2], fn(...parts) { parts })`, "[[1], [2]]"},
+		{`map([1, 2], fn(x, i = 5) { x + i })`, "[6, 7]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || isError(evaluated) || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestFunctionArgumentErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let add = fn(a, b) { a + b }; add(1)`, "wrong number of arguments to add: want=2, got=1"},
+		{`let add = fn(a, b) { a + b }; add(1, 2, 3)`, "wrong number of arguments to add: want=2, got=3"},
+		{`fn() { 1 }(1)`, "wrong number of arguments to <anonymous>: want=0, got=1"},
+		{`let f = fn(a, b = 2) { a }; f()`, "wrong number of arguments to f: want=1 or 2, got=0"},
+		{`let f = fn(a, b = 2, c = 3) { a }; f(1, 2, 3, 4)`, "wrong number of arguments to f: want=1 to 3, got=4"},
+		{`let f = fn(a, b, ...rest) { a }; f(1)`, "wrong number of arguments to f: want=at least 2, got=1"},
+		{`let f = fn(a, b) { a }; f(1, c: 2)`, "f has no parameter c"},
+		{`let f = fn(a, b) { a }; f(1, a: 2)`, "argument a to f given twice"},
+		{`let f = fn(a, b) { a }; f(b: 2)`, "missing argument a to f"},
+		{`let f = fn(a, ...rest) { a }; f(1, rest: 2)`, "f has no parameter rest"},
+		{`let f = fn(a, b = missing) { a }; f(1)`, "identifier not found: missing"},
+		{`let f = fn(a) { a }; f(...1)`, "cannot spread INTEGER, want ARRAY"},
+		{`len(x: "a")`, "builtin functions do not take named arguments, got x"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+// Calls in tail position must not grow the Go stack or count against the
+// call depth, so recursion can iterate as far as a loop could.
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(100000, 0)`, "100000"},
+		{`let sum = fn(xs, i, acc) { if (i == len(xs)) { return acc; } return sum(xs, i + 1, acc + xs[i]); }; sum(range(100000), 0, 0)`,
+			"4999950000"},
+		{`let f = fn(n) { if (n > 0) { return f(n - 1); } "done" }; f(100000)`, "done"},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(100001)`,
+			"false"},
+		{`let all = fn(n) { n == 0 || all(n - 1) }; all(100000)`, "true"},
+		{`let none = fn(n) { n > 0 && none(n - 1) }; none(100000)`, "false"},
+		{`let f = fn(n, acc = 0) { if (n == 0) { acc } else { f(n - 1, acc: acc + 2) } }; f(100000)`, "200000"},
+		{`let f = fn(n, ...seen) { if (n == 0) { len(seen) } else { f(n - 1, ...seen) } }; f(100000, 1, 2)`, "2"},
+		{`let count = fn(n) { if (n == 0) { "done" } else { count(n - 1) } }; map([100000], count)`, "[done]"},
+		{`let f = fn(n) { try { if (n == 0) { missing } else { f(n - 1) } } catch (e) { n } }; f(3)`, "0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -419,6 +1021,38 @@ func TestArrayConcatenation(t *testing.T) {
 	testIntegerObject(t, result.Elements[3], 4)
 }
 
+// A concatenation never shares its elements with its operands, even when the
+// left operand has spare capacity, so later concatenations and index
+// assignments cannot change an earlier result.
+func TestArrayConcatenationCopies(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1, 2, 3, 4, 5]; let b = a <> [1]; let c = a <> [2]; b`, "[1, 2, 3, 4, 5, 1]"},
+		{`let a = [1, 2, 3, 4, 5]; let b = a <> [1]; let c = a <> [2]; [a, b, c]`,
+			"[[1, 2, 3, 4, 5], [1, 2, 3, 4, 5, 1], [1, 2, 3, 4, 5, 2]]"},
+		{`let a = [1, 2, 3, 4, 5]; let b = a <> [1, 2]; let c = a <> [3]; b`, "[1, 2, 3, 4, 5, 1, 2]"},
+		{`let a = []; a <>= [1]; a <>= [2]; a <>= [3]; let b = a <> [4]; let c = a <> [5]; [b, c]`,
+			"[[1, 2, 3, 4], [1, 2, 3, 5]]"},
+		{`let a = [1, 2, 3, 4, 5]; let b = a <> [1]; let c = a <> [2]; c[5] = 9; c[0] = 0; [a, b, c]`,
+			"[[1, 2, 3, 4, 5], [1, 2, 3, 4, 5, 1], [0, 2, 3, 4, 5, 9]]"},
+		{`let a = [1, 2]; let b = a <> []; b[0] = 9; [a, b]`, "[[1, 2], [9, 2]]"},
+		{`let a = [1, 2]; let b = [] <> a; b[0] = 9; [a, b]`, "[[1, 2], [9, 2]]"},
+		{`let a = [1, 2]; let b = a; b <>= [3]; [a, b]`, "[[1, 2], [1, 2, 3]]"},
+		{`let a = [1]; a[0] = a; a`, "[[...]]"},
+		{`let h = {}; h["x"] = h; h`, "{x: {...}}"},
+		{`let a = [1]; let h = {"a": a}; a[0] = h; [a, h]`, "[[{a: [...]}], {a: [{...}]}]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
     {
@@ -436,26 +1070,30 @@ func TestHashLiterals(t *testing.T) {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for _, expected := range expected {
+		value, ok := result.Get(expected.key)
 		if !ok {
-			t.Errorf("no pair for given key in Pairs")
+			t.Errorf("no pair for key %s", expected.key.Inspect())
+			continue
 		}
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		testIntegerObject(t, value, expected.value)
 	}
 }
 
@@ -538,6 +1176,22 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+
+	if !ok {
+		t.Errorf("Expected Float object. Got %T (%+v) instead.", obj, obj)
+		return false
+	}
+
+	if math.Abs(result.Value-expected) > 1e-9 {
+		t.Errorf("Object has wrong value. Expected %g. Got %g instead.", expected, result.Value)
+		return false
+	}
+
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 
@@ -553,3 +1207,654 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 
 	return true
 }
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedInspect string
+	}{
+		{"5 + true;", "Error: 1:3: Type mismatch: INTEGER + BOOLEAN"},
+		{"let a = 1;\nlet b = a + foobar;", "Error: 2:13: identifier not found: foobar"},
+		{"let f = fn() {\n  -true\n};\nf();", "Error: 2:3: Unknown operator: -BOOLEAN"},
+		{`len(1)`, "Error: 1:4: argument to `len` not supported, got INTEGER"},
+		{"let ratio = fn(a, b) {\n  a / b\n};\nratio(1, 0);", "Error: 2:5: division by zero: 1 / 0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Inspect() != tt.expectedInspect {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expectedInspect, errObj.Inspect())
+		}
+	}
+}
+
+func TestExecutionLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	deadline, stop := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer stop()
+
+	tests := []struct {
+		input           string
+		ctx             context.Context
+		limits          object.Limits
+		expectedKind    object.ErrorKind
+		expectedMessage string
+	}{
+		{"let f = fn() { 1 + f() }; f()", context.Background(), object.Limits{},
+			object.CallDepthExceeded, "call depth limit exceeded (10000 calls)"},
+		{"let f = fn() { f() }; f()", context.Background(), object.Limits{},
+			object.CallDepthExceeded, "tail call limit exceeded (1000000 calls)"},
+		{"let a = fn() { b() }; let b = fn() { a() }; a()", context.Background(), object.Limits{MaxTailCalls: 50},
+			object.CallDepthExceeded, "tail call limit exceeded (50 calls)"},
+		{"let f = fn(n) { if (n > 0) { 1 + f(n - 1) } else { 0 } }; f(50)", context.Background(), object.Limits{MaxCallDepth: 20},
+			object.CallDepthExceeded, "call depth limit exceeded (20 calls)"},
+		{"let f = fn() { f() }; f()", context.Background(), object.Limits{MaxSteps: 1000},
+			object.StepLimitExceeded, "step limit exceeded (1000 steps)"},
+		{"let i = 0; while (true) { i += 1 }", context.Background(), object.Limits{MaxSteps: 500},
+			object.StepLimitExceeded, "step limit exceeded (500 steps)"},
+		{"let a = []; while (true) { a = a <> [1, 2, 3] }", context.Background(), object.Limits{MaxAllocations: 1000},
+			object.AllocationLimitExceeded, "allocation limit exceeded (1000 objects)"},
+		{"let s = \"x\"; while (true) { s = s <> s }", context.Background(), object.Limits{MaxAllocations: 1000},
+			object.AllocationLimitExceeded, "allocation limit exceeded (1000 objects)"},
+		{"range(1000000)", context.Background(), object.Limits{MaxAllocations: 1000},
+			object.AllocationLimitExceeded, "allocation limit exceeded (1000 objects)"},
+		{"repeat(\"abcdefgh\", 1000000)", context.Background(), object.Limits{MaxAllocations: 1000},
+			object.AllocationLimitExceeded, "allocation limit exceeded (1000 objects)"},
+		{"let a = range(500); push(a, 1)", context.Background(), object.Limits{MaxAllocations: 1000},
+			object.AllocationLimitExceeded, "allocation limit exceeded (1000 objects)"},
+		{"let a = range(300); zip(a, a, a)", context.Background(), object.Limits{MaxAllocations: 1000},
+			object.AllocationLimitExceeded, "allocation limit exceeded (1000 objects)"},
+		{"let a = range(300); flatten([a, a, a, a])", context.Background(), object.Limits{MaxAllocations: 1000},
+			object.AllocationLimitExceeded, "allocation limit exceeded (1000 objects)"},
+		{"join(range(10), repeat(\"x\", 1000))", context.Background(), object.Limits{MaxAllocations: 1000},
+			object.AllocationLimitExceeded, "allocation limit exceeded (1000 objects)"},
+		{"replace(\"aaaa\", \"a\", repeat(\"b\", 3000))", context.Background(), object.Limits{MaxAllocations: 1000},
+			object.AllocationLimitExceeded, "allocation limit exceeded (1000 objects)"},
+		{"10 ** 100000", context.Background(), object.Limits{MaxAllocations: 1000},
+			object.AllocationLimitExceeded, "allocation limit exceeded (1000 objects)"},
+		{"while (true) { }", canceled, object.Limits{},
+			object.Canceled, "execution canceled"},
+		{"let f = fn() { 1 + f() }; try { f() } catch (e) { e[\"kind\"] }", context.Background(), object.Limits{},
+			object.CallDepthExceeded, "call depth limit exceeded (10000 calls)"},
+		{"let f = fn() { f() }; try { f() } catch (e) { e[\"kind\"] }", context.Background(), object.Limits{},
+			object.CallDepthExceeded, "tail call limit exceeded (1000000 calls)"},
+		{"while (true) { try { while (true) { } } catch (e) { } }", context.Background(), object.Limits{MaxSteps: 500},
+			object.StepLimitExceeded, "step limit exceeded (500 steps)"},
+		{"let a = []; try { a = range(2000) } catch (e) { 0 } finally { a = [] }", context.Background(), object.Limits{MaxAllocations: 1000},
+			object.AllocationLimitExceeded, "allocation limit exceeded (1000 objects)"},
+		{"while (true) { try { while (true) { } } catch (e) { } }", deadline, object.Limits{},
+			object.DeadlineExceeded, "execution deadline exceeded"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		env.Meter().Reset(tt.ctx, tt.limits)
+
+		errObj, ok := Eval(program, env).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+
+		if errObj.Kind != tt.expectedKind || errObj.Message != tt.expectedMessage {
+			t.Errorf("%q: wrong error. expected=%s %q, got=%s %q",
+				tt.input, tt.expectedKind, tt.expectedMessage, errObj.Kind, errObj.Message)
+		}
+	}
+}
+
+// Builtins that can create values far larger than their arguments must
+// refuse them before allocating the memory.
+func TestBuiltinsCheckAllocationsFirst(t *testing.T) {
+	inputs := []string{
+		`range(4000000)`,
+		`repeat("abcdefgh", 2000000)`,
+		`let a = range(100); join(a, repeat("x", 100000))`,
+	}
+
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		env := object.NewEnvironment()
+		env.Meter().Reset(context.Background(), object.Limits{MaxAllocations: 1000})
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		result := Eval(program, env)
+		runtime.ReadMemStats(&after)
+
+		if errObj, ok := result.(*object.Error); !ok || errObj.Kind != object.AllocationLimitExceeded {
+			t.Errorf("%s: expected AllocationLimitExceeded, got %s", input, result.Inspect())
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("%s: allocated %d bytes before failing", input, allocated)
+		}
+	}
+}
+
+func TestLimitsAllowOrdinaryPrograms(t *testing.T) {
+	input := `
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	fib(15)`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	env.Meter().Reset(context.Background(), object.Limits{MaxSteps: 1000000, MaxCallDepth: 50, MaxAllocations: 1000000})
+
+	testIntegerObject(t, Eval(program, env), 610)
+}
+
+func TestStackTraces(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedTraceback string
+	}{
+		{"let inner = fn(x) {\n  x + true\n};\nlet outer = fn() { inner(1) };\nouter();",
+			"Error: 2:5: Type mismatch: INTEGER + BOOLEAN\n" +
+				"  at inner (called at 4:25)\n" +
+				"  at outer (called at 5:6)"},
+		{"let apply = fn(f) { f() };\napply(fn() { -true });",
+			"Error: 2:14: Unknown operator: -BOOLEAN\n" +
+				"  at <anonymous> (called at 1:22)\n" +
+				"  at apply (called at 2:6)"},
+		{"let add = fn(a, b) { a + b };\nlet twice = fn(a) { add(a) };\ntwice(1);",
+			"Error: 2:24: wrong number of arguments to add: want=2, got=1\n" +
+				"  at add (called at 2:24)\n" +
+				"  at twice (called at 3:6)"},
+		{"let down = fn(n) { if (n == 0) { missing } else { down(n - 1) } };\ndown(3);",
+			"Error: 1:34: identifier not found: missing\n" +
+				"  at down (called at 1:55)\n" +
+				"  ... repeated 2 more times\n" +
+				"  at down (called at 2:5)"},
+		{"let even = fn(n) { if (n == 0) { missing } else { odd(n - 1) } };\nlet odd = fn(n) { even(n - 1) };\neven(2);",
+			"Error: 1:34: identifier not found: missing\n" +
+				"  at even (called at 2:23)\n" +
+				"  at odd (called at 1:54)\n" +
+				"  at even (called at 3:5)"},
+		{"let down = fn(n) { if (n == 0) { missing } else { down(n - 1) } };\ndown(1000);",
+			"Error: 1:34: identifier not found: missing\n" +
+				"  at down (called at 1:55)\n" +
+				"  ... repeated 99 more times\n" +
+				"  ... 900 earlier tail calls not shown\n" +
+				"  at down (called at 2:5)"},
+		{"len(1)", "Error: 1:4: argument to `len` not supported, got INTEGER"},
+		{"let check = fn(x) { if (x > 1) { x + true } else { x } };\nlet run = fn() { map([1, 2], check) };\nrun();",
+			"Error: 1:36: Type mismatch: INTEGER + BOOLEAN\n" +
+				"  at check (called at 2:21)\n" +
+				"  at run (called at 3:4)"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+
+		if errObj.Traceback() != tt.expectedTraceback {
+			t.Errorf("wrong traceback.\nexpected=%q\ngot=     %q", tt.expectedTraceback, errObj.Traceback())
+		}
+	}
+}
+
+func TestErrorFields(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn() { 1 + true };\ntry { f() } catch (e) { e[\"message\"] }", "Type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn() { 1 + true };\ntry { f() } catch (e) { e[\"position\"] }", "1:18"},
+		{"let f = fn() { 1 + true };\ntry { f() } catch (e) { e[\"stack\"][0][\"function\"] }", "f"},
+		{"let f = fn() { 1 + true };\ntry { f() } catch (e) { e[\"stack\"][0][\"position\"] }", "2:8"},
+		{"try { 1 + true } catch (e) { len(e[\"stack\"]) }", 0},
+		{"try { 1 + true } catch (e) { e[\"kind\"] }", nil},
+		{"try { 1 + true } catch (e) { e[\"data\"] }", nil},
+		{"try { 1 + true } catch (e) { e[\"unknown\"] }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%q: want=%q, got=%v", tt.input, expected, evaluated)
+			}
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"try { 1 } catch (e) { 2 }", 1},
+		{"try { 1 + true; 3 } catch (e) { 2 }", 2},
+		{"try { throw \"bad\"; 1 } catch (e) { e[\"message\"] }", "bad"},
+		{"try { throw 42 } catch (e) { e[\"data\"] + 1 }", 43},
+		{"try { throw error(\"invalid input\", {\"line\": 7}) } catch (e) { e[\"data\"][\"line\"] }", 7},
+		{"try { throw error(\"invalid input\") } catch (e) { e[\"message\"] }", "invalid input"},
+		{"try { len(1, 2) } catch (e) { e[\"message\"] }", "wrong number of arguments. got=2, want=1"},
+		{"try { 10 / 0 } catch (e) { e[\"message\"] }", "division by zero: 10 / 0"},
+		{"try { [1][\"a\"] } catch (e) { e[\"message\"] }", "index operator not supported ARRAY"},
+		{"let log = []; try { 1 } finally { log = log <> [1] }; len(log)", 1},
+		{"let log = []; try { try { throw \"x\" } finally { log = log <> [1] } } catch (e) { len(log) }", 1},
+		{"let f = fn() { try { return 1 } finally { 2 } }; f()", 1},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"let f = fn() { try { throw \"x\" } catch (e) { return 3 } }; f()", 3},
+		{"let n = 0; while (true) { try { n += 1; if (n > 2) { break } } finally { n += 10 } } n", 22},
+		{"let f = fn(x) { if (x < 0) { throw \"negative\" } x }; let g = fn(x) { f(x) * 2 }; try { g(-1) } catch (e) { len(e[\"stack\"]) }", 2},
+		{"try { try { throw \"inner\" } catch (e) { throw e } } catch (e) { e[\"position\"] }", "1:13"},
+		{"let e = error(\"later\"); e[\"message\"]", "later"},
+		{"let x = try { 1 + true } catch (e) { 0 }; x", 0},
+		{"try { throw \"x\" } catch (e) { let scoped = 1 }; try { scoped } catch (e) { e[\"message\"] }", "identifier not found: scoped"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%q: want=%q, got=%v", tt.input, expected, evaluated)
+			}
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		}
+	}
+}
+
+func TestUncaughtThrow(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedTraceback string
+	}{
+		{"throw \"boom\"", "Error: 1:1: boom"},
+		{"let check = fn(x) {\n  throw error(\"bad value\", x)\n};\ncheck(1)", "Error: 2:3: bad value\n  at check (called at 4:6)"},
+		{"try { throw \"first\" } catch (e) { 1 } finally { throw \"second\" }", "Error: 1:49: second"},
+		{"try { 1 } catch (e) { 2 } finally { 1 + true }", "Error: 1:39: Type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+
+		if errObj.Traceback() != tt.expectedTraceback {
+			t.Errorf("wrong traceback.\nexpected=%q\ngot=     %q", tt.expectedTraceback, errObj.Traceback())
+		}
+	}
+}
+
+func writeModules(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testEvalFile(filename string, env *object.Environment) object.Object {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return newError("%s", err)
+	}
+	p := parser.New(lexer.NewFile(filename, string(src)))
+	return Eval(p.ParseProgram(), env)
+}
+
+func TestImports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"lib/math.mk": `
+			let square = fn(x) { x * x };
+			export let cube = fn(x) { x * square(x) };
+			export let counter = 0;
+			export let bump = fn() { counter += 1 };
+		`,
+		"lib/geometry.mk": `
+			import { cube } from "math";
+			export let volume = fn(side) { cube(side) };
+		`,
+		"vendor/strings.mk": `export let greeting = "hello";`,
+		"main.mk": `
+			let math = import "lib/math";
+			let again = import "lib/math.mk";
+			import { volume } from "lib/geometry";
+			import { greeting } from "strings";
+			math.bump();
+			again.bump();
+			[math.cube(2), volume(3), math.counter, greeting]
+		`,
+	})
+
+	env := object.NewEnvironment()
+	env.Modules().SearchPath = []string{filepath.Join(dir, "vendor")}
+
+	evaluated := testEvalFile(filepath.Join(dir, "main.mk"), env)
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	testIntegerObject(t, array.Elements[0], 8)
+	testIntegerObject(t, array.Elements[1], 27)
+	testIntegerObject(t, array.Elements[2], 2)
+	if str, ok := array.Elements[3].(*object.String); !ok || str.Value != "hello" {
+		t.Errorf("wrong greeting. got=%v", array.Elements[3])
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.mk":      `import { b } from "b"; export let a = 1;`,
+		"b.mk":      `import { a } from "a"; export let b = 2;`,
+		"lib.mk":    `let hidden = 1; export let shown = 2;`,
+		"broken.mk": `let = 1;`,
+		"fails.mk":  "export let x = 1;\nlet y = x + true;",
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "a"`, "circular import: " + filepath.Join(dir, "a.mk") + " -> " +
+			filepath.Join(dir, "b.mk") + " -> " + filepath.Join(dir, "a.mk")},
+		{`import "missing"`, `cannot find module "missing"`},
+		{`let lib = import "lib"; lib.hidden`, fmt.Sprintf("module %q has no export hidden", filepath.Join(dir, "lib.mk"))},
+		{`import { shown, hidden } from "lib";`, `module "lib" has no export hidden`},
+		{`import "broken"`, "cannot parse module " + filepath.Join(dir, "broken.mk") + ":\n\t" +
+			filepath.Join(dir, "broken.mk") + ":1:5: Expected IDENTIFIER, got = instead."},
+		{`let n = 1; n.x`, "member access not supported: INTEGER.x"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.NewFile(filepath.Join(dir, "main.mk"), tt.input))
+		evaluated := Eval(p.ParseProgram(), object.NewEnvironment())
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error message.\nexpected=%q\ngot=     %q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+
+	p := parser.New(lexer.NewFile(filepath.Join(dir, "main.mk"), `import "fails"`))
+	errObj, ok := Eval(p.ParseProgram(), object.NewEnvironment()).(*object.Error)
+	expected := "Error: " + filepath.Join(dir, "fails.mk") + ":2:11: Type mismatch: INTEGER + BOOLEAN\n" +
+		"  at import fails (called at " + filepath.Join(dir, "main.mk") + ":1:1)"
+	if !ok {
+		t.Fatalf("import of a failing module returned no error")
+	}
+	if errObj.Traceback() != expected {
+		t.Errorf("wrong traceback.\nexpected=%q\ngot=     %q", expected, errObj.Traceback())
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("héllo")`, "5"},
+		{`byteLen("héllo")`, "6"},
+		{`"héllo"[1]`, "é"},
+		{`"abc"[3]`, "null"},
+		{`"abc"[-1]`, "null"},
+		{`split("a,b,,c", ",")`, "[a, b, , c]"},
+		{"split(\"  one two\tthree \")", "[one, two, three]"},
+		{`split("héj", "")`, "[h, é, j]"},
+		{`join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`join([1, true, "x"])`, "1truex"},
+		{"trim(\"  hi \n\")", "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`trimLeft("  hi  ")`, "hi  "},
+		{`trimRight("  hi  ")`, "  hi"},
+		{`trimRight("hi!?!", "!?")`, "hi"},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("ÀB")`, "àb"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("a-b-c", "-", "+", 1)`, "a+b-c"},
+		{`contains("haystack", "st")`, "true"},
+		{`contains("haystack", "x")`, "false"},
+		{`startsWith("prefix", "pre")`, "true"},
+		{`endsWith("suffix", "pre")`, "false"},
+		{`indexOf("héllo", "l")`, "2"},
+		{`indexOf("hello", "z")`, "-1"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`substring("héllo", 1, 3)`, "él"},
+		{`substring("hello", 2)`, "llo"},
+		{`slice("hello", -3)`, "llo"},
+		{`slice("hello", 1, -1)`, "ell"},
+		{`slice("hello", 4, 1)`, ""},
+		{`slice("hello", -10, 10)`, "hello"},
+		{`chars("añb")`, "[a, ñ, b]"},
+		{`format("%s has %d items costing %.2f", "cart", 3, 2.5)`, "cart has 3 items costing 2.50"},
+		{`format("%5d|%-4s|%x|%q|%t|100%%", 42, "ab", 255, "hi", true)`, `   42|ab  |ff|"hi"|true|100%`},
+		{`format("%v and %v", [1, 2], 1.5)`, "[1, 2] and 1.5"},
+		{`format("%f", 1)`, "1.000000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || isError(evaluated) || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestStringBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split(1, ",")`, "argument 1 to `split` must be STRING, got INTEGER"},
+		{`split("a", ",", 1)`, "wrong number of arguments. got=3, want=1 or 2"},
+		{`replace("a")`, "wrong number of arguments. got=1, want=3 or 4"},
+		{`format()`, "wrong number of arguments. got=0, want=at least 1"},
+		{`join("abc")`, "argument 1 to `join` must be ARRAY, got STRING"},
+		{`repeat("a", -1)`, "negative count to `repeat`: -1"},
+		{`repeat("a", "b")`, "argument 2 to `repeat` must be INTEGER, got STRING"},
+		{`repeat("ab", 10000000000)`, "result of `repeat` too large: 10000000000 copies of 2 bytes exceeds 16777216"},
+		{`join(range(10), repeat("x", 2000000))`, "result of `join` too large: 18000010 bytes exceeds 16777216"},
+		{`replace(repeat("a", 1000), "a", repeat("b", 20000))`, "result of `replace` too large: 20000000 bytes exceeds 16777216"},
+		{`substring("abc", 2, 5)`, "substring bounds out of range [2:5] with length 3"},
+		{`contains(1, 2)`, "argument to `contains` not supported, got INTEGER"},
+		{`format("%d", "x")`, "format: %d does not accept STRING"},
+		{`format("%d %d", 1)`, "format: missing argument for %d"},
+		{`format("%d", 1, 2)`, "format: 2 arguments given, 1 used"},
+		{`format("%y", 1)`, "format: unknown verb %y"},
+		{`format("50%")`, `format: missing verb at end of "50%"`},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestArrayBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`first([1, 2, 3])`, "1"},
+		{`first([])`, "null"},
+		{`last([1, 2, 3])`, "3"},
+		{`rest([1, 2, 3])`, "[2, 3]"},
+		{`rest([])`, "null"},
+		{`let a = [1]; let b = push(a, 2, 3); [a, b]`, "[[1], [1, 2, 3]]"},
+		{`let a = [1, 2]; let b = pop(a); [a, b]`, "[[1, 2], [1]]"},
+		{`slice([1, 2, 3, 4], 1, 3)`, "[2, 3]"},
+		{`slice([1, 2, 3, 4], -2)`, "[3, 4]"},
+		{`let a = [1, 2, 3]; let b = reverse(a); [a, b]`, "[[1, 2, 3], [3, 2, 1]]"},
+		{`reverse("héllo")`, "olléh"},
+		{`contains([1, "a", 2.5], "a")`, "true"},
+		{`contains([1, 2], 3)`, "false"},
+		{`contains([1, 2], 2.0)`, "true"},
+		{`indexOf([5, 6, 7], 7)`, "2"},
+		{`indexOf([5, 6, 7], "7")`, "-1"},
+		{`sort([3, 1, 2.5])`, "[1, 2.5, 3]"},
+		{`sort(["pear", "apple", "fig"])`, "[apple, fig, pear]"},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort(["ccc", "a", "bb"], fn(a, b) { len(a) - len(b) })`, "[a, bb, ccc]"},
+		{`sort([[2, "b"], [1, "a"], [2, "a"]], fn(x, y) { x[0] < y[0] })`, "[[1, a], [2, b], [2, a]]"},
+		{`map([1, 2, 3], fn(x) { x * x })`, "[1, 4, 9]"},
+		{`map(["a", "b"], fn(x, i) { format("%s%d", x, i) })`, "[a0, b1]"},
+		{`map([1, 2, 3], fn(x, scale = 10) { x * scale })`, "[10, 20, 30]"},
+		{`map([1, 2], fn(x, i, scale = 10) { x * scale + i })`, "[10, 21]"},
+		{`map([" a ", "b "], trim)`, "[a, b]"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3, 4]"},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x })`, "6"},
+		{`reduce([1, 2, 3], fn(acc, x) { acc <> [x * 2] }, [])`, "[2, 4, 6]"},
+		{`find([1, 2, 3], fn(x) { x > 1 })`, "2"},
+		{`find([1, 2, 3], fn(x) { x > 5 })`, "null"},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, "true"},
+		{`any([], fn(x) { true })`, "false"},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, "true"},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, "false"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`flatten([1, [2, [3, [4]]]])`, "[1, 2, [3, [4]]]"},
+		{`flatten([1, [2, [3, [4]]]], 10)`, "[1, 2, 3, 4]"},
+		{`range(4)`, "[0, 1, 2, 3]"},
+		{`range(2, 5)`, "[2, 3, 4]"},
+		{`range(10, 0, -3)`, "[10, 7, 4, 1]"},
+		{`range(0, 10, 4)`, "[0, 4, 8]"},
+		{`range(5, 1)`, "[]"},
+		{`range(0, 9223372036854775807, 9223372036854775807)`, "[0]"},
+		{`range(9223372036854775807, -9223372036854775807 - 1, -9223372036854775807 - 1)`, "[9223372036854775807, -1]"},
+		{`range(9223372036854775806, 9223372036854775807)`, "[9223372036854775806]"},
+		{`let total = fn(xs) { let n = 0; map(xs, fn(x) { n += x }); n }; total([1, 2, 3])`, "6"},
+		{`let make = fn(n) { map(range(n), fn(i) { map(range(i), fn(j) { j }) }) }; make(3)`, "[[], [0], [0, 1]]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || isError(evaluated) || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestArrayBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`first(1)`, "argument 1 to `first` must be ARRAY, got INTEGER"},
+		{`push([])`, "wrong number of arguments. got=1, want=at least 2"},
+		{`pop([])`, "pop from empty ARRAY"},
+		{`sort([1, "a"])`, "cannot sort STRING and INTEGER without a comparator"},
+		{`sort([1, 2], fn(a, b) { "yes" })`, "comparator for `sort` must return BOOLEAN or INTEGER, got STRING"},
+		{`map([1], 2)`, "argument 2 to `map` must be FUNCTION, got INTEGER"},
+		{`map([1, 2], fn(x) { x + "a" })`, "Type mismatch: INTEGER + STRING"},
+		{`reduce([], fn(a, b) { a + b })`, "reduce of empty ARRAY with no initial value"},
+		{`filter([1], fn(a, b, c) { true })`, "wrong number of arguments to <anonymous>: want=3, got=2"},
+		{`range(0, 10, 0)`, "range step must not be 0"},
+		{`range(1, "a")`, "argument 2 to `range` must be INTEGER, got STRING"},
+		{`range(2000000000)`, "result of `range` too large: 2000000000 elements exceeds 4194304"},
+		{`range(-9223372036854775807 - 1, 9223372036854775807)`, "result of `range` too large: 18446744073709551615 elements exceeds 4194304"},
+		{`range(9223372036854775807, -9223372036854775807 - 1, -1)`, "result of `range` too large: 18446744073709551615 elements exceeds 4194304"},
+		{`let a = range(3000000); zip(a, a)`, "result of `zip` too large: 9000000 elements exceeds 4194304"},
+		{`let a = [1]; a[0] = a; flatten(a, 3)`, "cannot flatten an ARRAY that contains itself"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: true, false: 4}`, "{b: 1, a: 2, 3: true, false: 4}"},
+		{`let h = {"b": 1, "a": 2}; h["c"] = 3; h["b"] = 4; h`, "{b: 4, a: 2, c: 3}"},
+		{`let out = []; for (k, v in {"z": 1, "y": 2, "x": 3}) { out = out <> [k] }; out`, "[z, y, x]"},
+		{`keys({"z": 1, "y": 2})`, "[z, y]"},
+		{`values({"z": 1, "y": 2})`, "[1, 2]"},
+		{`entries({"z": 1, "y": 2})`, "[[z, 1], [y, 2]]"},
+		{`has({"a": if (false) { 1 }}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; let d = delete(h, "a", "c", "x"); [h, d]`, "[{a: 1, b: 2, c: 3}, {b: 2}]"},
+		{`merge({"a": 1, "b": 2}, {"c": 3, "a": 4})`, "{a: 4, b: 2, c: 3}"},
+		{`merge({})`, "{}"},
+		{`get({"a": 1}, "a", 0)`, "1"},
+		{`get({"a": 1}, "b", 0)`, "0"},
+		{`get({"a": 1}, "b")`, "null"},
+		{`toArray({"a": 1})`, "[[a, 1]]"},
+		{`toArray("ab")`, "[a, b]"},
+		{`let a = [1]; let b = toArray(a); b[0] = 2; [a, b]`, "[[1], [2]]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || isError(evaluated) || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestHashBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`keys([1])`, "argument 1 to `keys` must be HASH, got ARRAY"},
+		{`has({}, [1])`, "unusable as hash key: ARRAY"},
+		{`delete({}, fn() {})`, "unusable as hash key: FUNCTION"},
+		{`merge({}, 1)`, "argument 2 to `merge` must be HASH, got INTEGER"},
+		{`get({})`, "wrong number of arguments. got=1, want=2 or 3"},
+		{`toArray(1)`, "argument to `toArray` not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package evaluator
+
+import "interpreter/object"
+
+// Hash builtins list pairs in insertion order and, like the array
+// builtins, return new hashes rather than modifying their argument.
+
+func builtInKeys(args ...object.Object) object.Object {
+	hash, err := singleHash("keys", args)
+	if err != nil {
+		return err
+	}
+	pairs := hash.Ordered()
+	keys := make([]object.Object, len(pairs))
+	for i, pair := range pairs {
+		keys[i] = pair.Key
+	}
+	return &object.Array{Elements: keys}
+}
+
+func builtInValues(args ...object.Object) object.Object {
+	hash, err := singleHash("values", args)
+	if err != nil {
+		return err
+	}
+	pairs := hash.Ordered()
+	values := make([]object.Object, len(pairs))
+	for i, pair := range pairs {
+		values[i] = pair.Value
+	}
+	return &object.Array{Elements: values}
+}
+
+// builtInEntries returns the pairs of a hash as [key, value] arrays.
+func builtInEntries(args ...object.Object) object.Object {
+	hash, err := singleHash("entries", args)
+	if err != nil {
+		return err
+	}
+	return hashEntries(hash)
+}
+
+func hashEntries(hash *object.Hash) *object.Array {
+	pairs := hash.Ordered()
+	entries := make([]object.Object, len(pairs))
+	for i, pair := range pairs {
+		entries[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+	}
+	return &object.Array{Elements: entries}
+}
+
+func builtInHas(args ...object.Object) object.Object {
+	if err := checkArity(args, 2, 2); err != nil {
+		return err
+	}
+	hash, key, err := hashAndKey("has", args)
+	if err != nil {
+		return err
+	}
+	_, ok := hash.Get(key)
+	return nativeBoolToBooleanObject(ok)
+}
+
+// builtInDelete returns a copy of the hash without the given keys.
+func builtInDelete(args ...object.Object) object.Object {
+	if err := checkArity(args, 2, -1); err != nil {
+		return err
+	}
+	hash, err := hashArg("delete", args, 0)
+	if err != nil {
+		return err
+	}
+
+	copied := copyHash(hash)
+	for _, arg := range args[1:] {
+		key, ok := arg.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", arg.Type())
+		}
+		copied.Delete(key)
+	}
+	return copied
+}
+
+// builtInMerge combines hashes into a new one. Where several have the same
+// key the last one's value wins, in the position the key first appeared.
+func builtInMerge(args ...object.Object) object.Object {
+	if err := checkArity(args, 1, -1); err != nil {
+		return err
+	}
+	merged := object.NewHash()
+	for i := range args {
+		hash, err := hashArg("merge", args, i)
+		if err != nil {
+			return err
+		}
+		for _, pair := range hash.Ordered() {
+			merged.Set(pair.Key.(object.Hashable), pair.Value)
+		}
+	}
+	return merged
+}
+
+// builtInGet looks up a key, returning the default, or null, when the key
+// is missing. Unlike indexing it tells a missing key from a null value.
+func builtInGet(args ...object.Object) object.Object {
+	if err := checkArity(args, 2, 3); err != nil {
+		return err
+	}
+	hash, key, err := hashAndKey("get", args)
+	if err != nil {
+		return err
+	}
+	if value, ok := hash.Get(key); ok {
+		return value
+	}
+	if len(args) == 3 {
+		return args[2]
+	}
+	return NULL
+}
+
+// builtInToArray converts a collection to an array: a hash to its entries,
+// a string to its characters and an array to a copy of itself.
+func builtInToArray(args ...object.Object) object.Object {
+	if err := checkArity(args, 1, 1); err != nil {
+		return err
+	}
+	switch arg := args[0].(type) {
+	case *object.Hash:
+		return hashEntries(arg)
+	case *object.String:
+		return builtInChars(arg)
+	case *object.Array:
+		return newArray(arg.Elements)
+	default:
+		return newError("argument to `toArray` not supported, got %s", args[0].Type())
+	}
+}
+
+func copyHash(hash *object.Hash) *object.Hash {
+	copied := object.NewHash()
+	for _, pair := range hash.Ordered() {
+		copied.Set(pair.Key.(object.Hashable), pair.Value)
+	}
+	return copied
+}
+
+func singleHash(name string, args []object.Object) (*object.Hash, *object.Error) {
+	if err := checkArity(args, 1, 1); err != nil {
+		return nil, err
+	}
+	return hashArg(name, args, 0)
+}
+
+func hashAndKey(name string, args []object.Object) (*object.Hash, object.Hashable, *object.Error) {
+	hash, err := hashArg(name, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := args[1].(object.Hashable)
+	if !ok {
+		return nil, nil, newError("unusable as hash key: %s", args[1].Type())
+	}
+	return hash, key, nil
+}
+
+func hashArg(name string, args []object.Object, i int) (*object.Hash, *object.Error) {
+	hash, ok := args[i].(*object.Hash)
+	if !ok {
+		return nil, newError("argument %d to `%s` must be HASH, got %s", i+1, name, args[i].Type())
+	}
+	return hash, nil
+}
@@ -0,0 +1,237 @@
+package evaluator
+
+import (
+	"cmp"
+	"interpreter/object"
+	"math"
+	"math/big"
+)
+
+// Integers are 64-bit Integer objects until a result overflows, when they
+// become BigInt objects of arbitrary precision. Results that fit in 64 bits
+// again become Integer objects, so each value has a single representation.
+//
+// As in Go, / truncates toward zero and % takes the sign of the dividend:
+// -7 / 2 is -3 and -7 % 2 is -1. div rounds toward negative infinity, so
+// -7 div 2 is -4. Dividing an integer by zero is an error.
+
+// maxIntegerBits bounds the size of the results of ** and <<, which can
+// otherwise ask for more memory than there is with a short expression.
+const maxIntegerBits = 1 << 24
+
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	l, leftSmall := left.(*object.Integer)
+	r, rightSmall := right.(*object.Integer)
+	if leftSmall && rightSmall {
+		if result, ok := evalSmallIntegerInfixExpression(operator, l.Value, r.Value); ok {
+			return result
+		}
+	}
+	return evalBigIntegerInfixExpression(operator, toBig(left), toBig(right))
+}
+
+// evalSmallIntegerInfixExpression does 64-bit arithmetic. It reports false
+// if the result overflows.
+func evalSmallIntegerInfixExpression(operator string, leftVal, rightVal int64) (object.Object, bool) {
+	switch operator {
+	case "+":
+		sum := leftVal + rightVal
+		return &object.Integer{Value: sum}, (sum^leftVal)&(sum^rightVal) >= 0
+	case "-":
+		difference := leftVal - rightVal
+		return &object.Integer{Value: difference}, (leftVal^rightVal)&(difference^leftVal) >= 0
+	case "*":
+		product, ok := multiply(leftVal, rightVal)
+		return &object.Integer{Value: product}, ok
+	case "/", "%", "div":
+		if rightVal == 0 {
+			return newError("division by zero: %d %s 0", leftVal, operator), true
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return nil, false
+		}
+		quotient, remainder := leftVal/rightVal, leftVal%rightVal
+		switch {
+		case operator == "%":
+			return &object.Integer{Value: remainder}, true
+		case operator == "div" && remainder != 0 && (remainder < 0) != (rightVal < 0):
+			quotient--
+		}
+		return &object.Integer{Value: quotient}, true
+	case "**":
+		if rightVal < 0 {
+			return &object.Float{Value: math.Pow(float64(leftVal), float64(rightVal))}, true
+		}
+		power, ok := intPow(leftVal, rightVal)
+		return &object.Integer{Value: power}, ok
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}, true
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}, true
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}, true
+	case "<<":
+		if rightVal < 0 || rightVal > 62 {
+			return nil, false
+		}
+		shifted := leftVal << rightVal
+		return &object.Integer{Value: shifted}, shifted>>rightVal == leftVal
+	case ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal), true
+		}
+		return &object.Integer{Value: leftVal >> rightVal}, true
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal), true
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal), true
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal), true
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal), true
+	default:
+		return newError("Unknown operator: INTEGER %s INTEGER", operator), true
+	}
+}
+
+func evalBigIntegerInfixExpression(operator string, leftVal, rightVal *big.Int) object.Object {
+	switch operator {
+	case "+":
+		return newInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return newInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return newInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/", "%", "div":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero: %s %s 0", leftVal, operator)
+		}
+		quotient, remainder := new(big.Int).QuoRem(leftVal, rightVal, new(big.Int))
+		switch {
+		case operator == "%":
+			return newInteger(remainder)
+		case operator == "div" && remainder.Sign() != 0 && remainder.Sign() != rightVal.Sign():
+			quotient.Sub(quotient, big.NewInt(1))
+		}
+		return newInteger(quotient)
+	case "**":
+		if rightVal.Sign() < 0 {
+			return &object.Float{Value: math.Pow(bigToFloat(leftVal), bigToFloat(rightVal))}
+		}
+		if leftVal.CmpAbs(big.NewInt(1)) > 0 &&
+			(!rightVal.IsInt64() || rightVal.Int64() > maxIntegerBits/int64(leftVal.BitLen()-1)) {
+			return newError("integer too large: %s ** %s exceeds %d bits", leftVal, rightVal, maxIntegerBits)
+		}
+		return newInteger(new(big.Int).Exp(leftVal, rightVal, nil))
+	case "&":
+		return newInteger(new(big.Int).And(leftVal, rightVal))
+	case "|":
+		return newInteger(new(big.Int).Or(leftVal, rightVal))
+	case "^":
+		return newInteger(new(big.Int).Xor(leftVal, rightVal))
+	case "<<", ">>":
+		if rightVal.Sign() < 0 {
+			return newError("negative shift count: %s", rightVal)
+		}
+		if operator == ">>" {
+			if !rightVal.IsInt64() {
+				// Every bit is shifted out, leaving the sign.
+				return &object.Integer{Value: min(int64(leftVal.Sign()), 0)}
+			}
+			return newInteger(new(big.Int).Rsh(leftVal, uint(rightVal.Int64())))
+		}
+		if leftVal.Sign() == 0 {
+			return &object.Integer{Value: 0}
+		}
+		if !rightVal.IsInt64() || rightVal.Int64() > maxIntegerBits-int64(leftVal.BitLen()) {
+			return newError("integer too large: %s << %s exceeds %d bits", leftVal, rightVal, maxIntegerBits)
+		}
+		return newInteger(new(big.Int).Lsh(leftVal, uint(rightVal.Int64())))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	default:
+		return newError("Unknown operator: INTEGER %s INTEGER", operator)
+	}
+}
+
+// multiply reports false if the product overflows.
+func multiply(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
+}
+
+// intPow raises base to a non-negative exponent by repeated squaring,
+// reporting false if the result overflows.
+func intPow(base, exponent int64) (int64, bool) {
+	result := int64(1)
+	for {
+		var ok bool
+		if exponent&1 == 1 {
+			if result, ok = multiply(result, base); !ok {
+				return 0, false
+			}
+		}
+		exponent >>= 1
+		if exponent == 0 {
+			return result, true
+		}
+		if base, ok = multiply(base, base); !ok {
+			return 0, false
+		}
+	}
+}
+
+func negateInteger(operand object.Object) object.Object {
+	if i, ok := operand.(*object.Integer); ok && i.Value != math.MinInt64 {
+		return &object.Integer{Value: -i.Value}
+	}
+	return newInteger(new(big.Int).Neg(toBig(operand)))
+}
+
+// compareIntegers returns -1, 0 or 1 as left is less than, equal to or
+// greater than right.
+func compareIntegers(left, right object.Object) int {
+	l, leftSmall := left.(*object.Integer)
+	r, rightSmall := right.(*object.Integer)
+	if leftSmall && rightSmall {
+		return cmp.Compare(l.Value, r.Value)
+	}
+	return toBig(left).Cmp(toBig(right))
+}
+
+// newInteger returns n as an Integer if it fits in 64 bits and as a BigInt
+// otherwise.
+func newInteger(n *big.Int) object.Object {
+	if n.IsInt64() {
+		return &object.Integer{Value: n.Int64()}
+	}
+	return &object.BigInt{Value: n}
+}
+
+func toBig(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	default:
+		return new(big.Int)
+	}
+}
+
+func bigToFloat(n *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(n).Float64()
+	return f
+}
@@ -0,0 +1,140 @@
+package evaluator
+
+import (
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ModuleExtension is appended to import paths that do not name a file
+// themselves, so `import "lib/strings"` finds lib/strings.mk.
+const ModuleExtension = ".mk"
+
+// evalImport loads the module at path, imported from the file pos is in.
+// Each module is evaluated once per program, in its own top-level
+// environment; later imports of it get the cached module.
+func evalImport(path string, pos token.Position, env *object.Environment) object.Object {
+	modules := env.Modules()
+
+	filename, ok := resolveImport(path, pos.Filename, modules.SearchPath)
+	if !ok {
+		return newError("cannot find module %q", path)
+	}
+
+	if module, ok := modules.Lookup(filename); ok {
+		return module
+	}
+
+	if cycle, ok := modules.Begin(filename); !ok {
+		return newError("circular import: %s", cycle)
+	}
+	module, err := loadModule(filename, env)
+	modules.End(module)
+
+	if err != nil {
+		err.Stack = append(err.Stack, object.StackFrame{Function: "import " + path, Position: pos})
+		return err
+	}
+	return module
+}
+
+// resolveImport finds the file an import refers to. Relative paths are
+// looked up next to the importing file first and then in each directory of
+// the search path, both as given and with ModuleExtension appended.
+func resolveImport(path, importer string, searchPath []string) (string, bool) {
+	var dirs []string
+	if filepath.IsAbs(path) {
+		dirs = []string{""}
+	} else {
+		dirs = append([]string{filepath.Dir(importer)}, searchPath...)
+	}
+
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, path)
+		for _, name := range []string{candidate, candidate + ModuleExtension} {
+			if info, err := os.Stat(name); err == nil && !info.IsDir() {
+				if abs, err := filepath.Abs(name); err == nil {
+					return abs, true
+				}
+				return name, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func loadModule(filename string, importer *object.Environment) (*object.Module, *object.Error) {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, newError("cannot read module: %s", err)
+	}
+
+	par := parser.New(lexer.NewFile(filename, string(source)))
+	program := par.ParseProgram()
+	if errors := par.Errors(); len(errors) != 0 {
+		return nil, newError("cannot parse module %s:\n\t%s", filename, strings.Join(errors, "\n\t"))
+	}
+
+	module := &object.Module{
+		Path:    filename,
+		Env:     object.NewModuleEnvironment(importer),
+		Exports: map[string]bool{},
+	}
+	if result, ok := Eval(program, module.Env).(*object.Error); ok {
+		return nil, result
+	}
+
+	for _, statement := range program.Statements {
+		if export, ok := statement.(*ast.ExportStatement); ok {
+			module.Exports[export.Statement.Name.Value] = true
+		}
+	}
+
+	return module, nil
+}
+
+// evalImportStatement binds each of the names listed in the import to the
+// module's export of the same name.
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	result := evalImport(node.Path.Value, node.Pos(), env)
+	module, ok := result.(*object.Module)
+	if !ok {
+		return result
+	}
+
+	for _, name := range node.Names {
+		value, ok := module.Get(name.Value)
+		if !ok {
+			err := newError("module %q has no export %s", node.Path.Value, name.Value)
+			err.Position = name.Pos()
+			return err
+		}
+		env.Set(name.Value, value)
+	}
+
+	return nil
+}
+
+func evalMemberExpression(node *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := Eval(node.Object, env)
+	if isError(obj) {
+		return obj
+	}
+
+	module, ok := obj.(*object.Module)
+	if !ok {
+		return newError("member access not supported: %s.%s", obj.Type(), node.Member.Value)
+	}
+
+	value, ok := module.Get(node.Member.Value)
+	if !ok {
+		return newError("module %q has no export %s", module.Path, node.Member.Value)
+	}
+	return value
+}
@@ -0,0 +1,74 @@
+package evaluator
+
+import (
+	"interpreter/object"
+	"math/big"
+	"sort"
+)
+
+// The functions below expose the evaluator's object-level semantics so that
+// the bytecode VM applies exactly the same rules for operators, indexing,
+// truthiness and builtins as the tree-walker does.
+
+func EvalInfix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+func EvalPrefix(operator string, operand object.Object) object.Object {
+	return evalPrefixExpression(operator, operand)
+}
+
+func EvalIndex(collection, index object.Object) object.Object {
+	return evalIndexExpression(collection, index)
+}
+
+func EvalIndexAssignment(collection, index, value object.Object) object.Object {
+	return evalIndexAssignment(collection, index, value)
+}
+
+// ApplyFunction calls a function or builtin with already evaluated
+// arguments, the way a call expression does. A builtin accounts for what
+// it creates with meter, which may be nil.
+func ApplyFunction(meter *object.Meter, fn object.Object, args []object.Object) object.Object {
+	return applyFunction(meter, fn, args, nil)
+}
+
+func ArityError(name string, min, max, got int) *object.Error {
+	return arityError(name, min, max, got)
+}
+
+// NewInteger returns n as an Integer, or as a BigInt if it does not fit
+// in 64 bits.
+func NewInteger(n *big.Int) object.Object {
+	return newInteger(n)
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+func NewError(format string, a ...interface{}) *object.Error {
+	return newError(format, a...)
+}
+
+var builtinNames = sortedBuiltinNames()
+
+func sortedBuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuiltinNames lists the builtin functions in a fixed order, so that the
+// compiler and the VM can refer to a builtin by its index.
+func BuiltinNames() []string {
+	return builtinNames
+}
+
+func LookupBuiltin(name string) (*object.BuiltIn, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
@@ -0,0 +1,477 @@
+package evaluator
+
+import (
+	"fmt"
+	"interpreter/object"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// String builtins count positions and lengths in characters (runes), not
+// bytes, so that indexes from indexOf can be passed to substring and slice.
+
+func builtInByteLen(args ...object.Object) object.Object {
+	if err := checkArity(args, 1, 1); err != nil {
+		return err
+	}
+	s, err := stringArg("byteLen", args, 0)
+	if err != nil {
+		return err
+	}
+	return &object.Integer{Value: int64(len(s))}
+}
+
+// builtInSplit splits a string around each occurrence of a separator, or
+// around runs of whitespace when no separator is given. An empty separator
+// splits the string into characters.
+func builtInSplit(args ...object.Object) object.Object {
+	if err := checkArity(args, 1, 2); err != nil {
+		return err
+	}
+	s, err := stringArg("split", args, 0)
+	if err != nil {
+		return err
+	}
+
+	var parts []string
+	if len(args) == 1 {
+		parts = strings.Fields(s)
+	} else {
+		sep, err := stringArg("split", args, 1)
+		if err != nil {
+			return err
+		}
+		parts = strings.Split(s, sep)
+	}
+	return stringArray(parts)
+}
+
+// builtInJoin concatenates the elements of an array with a separator
+// between them. Elements that are not strings are written as puts would.
+func builtInJoin(meter *object.Meter, args ...object.Object) object.Object {
+	if err := checkArity(args, 1, 2); err != nil {
+		return err
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument 1 to `join` must be ARRAY, got %s", args[0].Type())
+	}
+	sep := ""
+	if len(args) == 2 {
+		var err *object.Error
+		if sep, err = stringArg("join", args, 1); err != nil {
+			return err
+		}
+	}
+
+	parts := make([]string, len(array.Elements))
+	length := int64(max(len(parts)-1, 0)) * int64(len(sep))
+	for i, element := range array.Elements {
+		parts[i] = element.Inspect()
+		length += int64(len(parts[i]))
+	}
+	if err := checkStringSize(meter, "join", length); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Join(parts, sep)}
+}
+
+// trimmer makes trim, trimLeft and trimRight. Without a second argument
+// they remove whitespace; with one, any of the characters it contains.
+func trimmer(name string, spaces func(string) string, cutset func(string, string) string) object.BuiltInFunction {
+	return func(args ...object.Object) object.Object {
+		if err := checkArity(args, 1, 2); err != nil {
+			return err
+		}
+		s, err := stringArg(name, args, 0)
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			return &object.String{Value: spaces(s)}
+		}
+		chars, err := stringArg(name, args, 1)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: cutset(s, chars)}
+	}
+}
+
+func trimLeftSpace(s string) string  { return strings.TrimLeftFunc(s, unicode.IsSpace) }
+func trimRightSpace(s string) string { return strings.TrimRightFunc(s, unicode.IsSpace) }
+
+// stringMapper makes a builtin that transforms a single string.
+func stringMapper(name string, fn func(string) string) object.BuiltInFunction {
+	return func(args ...object.Object) object.Object {
+		if err := checkArity(args, 1, 1); err != nil {
+			return err
+		}
+		s, err := stringArg(name, args, 0)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: fn(s)}
+	}
+}
+
+// stringPredicate makes a builtin that tests a string against another.
+func stringPredicate(name string, fn func(string, string) bool) object.BuiltInFunction {
+	return func(args ...object.Object) object.Object {
+		if err := checkArity(args, 2, 2); err != nil {
+			return err
+		}
+		s, err := stringArg(name, args, 0)
+		if err != nil {
+			return err
+		}
+		other, err := stringArg(name, args, 1)
+		if err != nil {
+			return err
+		}
+		return nativeBoolToBooleanObject(fn(s, other))
+	}
+}
+
+// builtInReplace replaces every occurrence of old, or only the first n
+// when a count is given.
+func builtInReplace(meter *object.Meter, args ...object.Object) object.Object {
+	if err := checkArity(args, 3, 4); err != nil {
+		return err
+	}
+	var strs [3]string
+	for i := range strs {
+		s, err := stringArg("replace", args, i)
+		if err != nil {
+			return err
+		}
+		strs[i] = s
+	}
+	n := int64(-1)
+	if len(args) == 4 {
+		var err *object.Error
+		if n, err = integerArg("replace", args, 3); err != nil {
+			return err
+		}
+	}
+	count := int64(strings.Count(strs[0], strs[1]))
+	if n >= 0 {
+		count = min(count, n)
+	}
+	length := int64(len(strs[0])) + count*int64(len(strs[2])-len(strs[1]))
+	if err := checkStringSize(meter, "replace", length); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Replace(strs[0], strs[1], strs[2], int(n))}
+}
+
+func builtInContains(args ...object.Object) object.Object {
+	if err := checkArity(args, 2, 2); err != nil {
+		return err
+	}
+	switch collection := args[0].(type) {
+	case *object.String:
+		sub, err := stringArg("contains", args, 1)
+		if err != nil {
+			return err
+		}
+		return nativeBoolToBooleanObject(strings.Contains(collection.Value, sub))
+	case *object.Array:
+		return nativeBoolToBooleanObject(arrayIndexOf(collection.Elements, args[1]) >= 0)
+	default:
+		return newError("argument to `contains` not supported, got %s", args[0].Type())
+	}
+}
+
+// builtInIndexOf returns the position of the first occurrence, or -1.
+func builtInIndexOf(args ...object.Object) object.Object {
+	if err := checkArity(args, 2, 2); err != nil {
+		return err
+	}
+	switch collection := args[0].(type) {
+	case *object.String:
+		sub, err := stringArg("indexOf", args, 1)
+		if err != nil {
+			return err
+		}
+		i := strings.Index(collection.Value, sub)
+		if i >= 0 {
+			i = utf8.RuneCountInString(collection.Value[:i])
+		}
+		return &object.Integer{Value: int64(i)}
+	case *object.Array:
+		return &object.Integer{Value: int64(arrayIndexOf(collection.Elements, args[1]))}
+	default:
+		return newError("argument to `indexOf` not supported, got %s", args[0].Type())
+	}
+}
+
+func builtInRepeat(meter *object.Meter, args ...object.Object) object.Object {
+	if err := checkArity(args, 2, 2); err != nil {
+		return err
+	}
+	s, err := stringArg("repeat", args, 0)
+	if err != nil {
+		return err
+	}
+	count, err := integerArg("repeat", args, 1)
+	if err != nil {
+		return err
+	}
+	if count < 0 {
+		return newError("negative count to `repeat`: %d", count)
+	}
+	if len(s) > 0 && count > maxStringLength/int64(len(s)) {
+		return newError("result of `repeat` too large: %d copies of %d bytes exceeds %d", count, len(s), maxStringLength)
+	}
+	if err := checkStringSize(meter, "repeat", count*int64(len(s))); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Repeat(s, int(count))}
+}
+
+// builtInSubstring returns the characters from start up to, but not
+// including, end, which defaults to the end of the string. Both must lie
+// within the string.
+func builtInSubstring(args ...object.Object) object.Object {
+	if err := checkArity(args, 2, 3); err != nil {
+		return err
+	}
+	s, err := stringArg("substring", args, 0)
+	if err != nil {
+		return err
+	}
+	runes := []rune(s)
+
+	start, end, err := rangeArgs("substring", args, int64(len(runes)))
+	if err != nil {
+		return err
+	}
+	if start < 0 || end > int64(len(runes)) || start > end {
+		return newError("substring bounds out of range [%d:%d] with length %d", start, end, len(runes))
+	}
+	return &object.String{Value: string(runes[start:end])}
+}
+
+// builtInSlice takes part of a string or array. Unlike substring, negative
+// positions count from the end and positions past either end are clamped.
+func builtInSlice(args ...object.Object) object.Object {
+	if err := checkArity(args, 2, 3); err != nil {
+		return err
+	}
+	switch collection := args[0].(type) {
+	case *object.String:
+		runes := []rune(collection.Value)
+		start, end, err := rangeArgs("slice", args, int64(len(runes)))
+		if err != nil {
+			return err
+		}
+		start, end = clampRange(start, end, int64(len(runes)))
+		return &object.String{Value: string(runes[start:end])}
+	case *object.Array:
+		length := int64(len(collection.Elements))
+		start, end, err := rangeArgs("slice", args, length)
+		if err != nil {
+			return err
+		}
+		start, end = clampRange(start, end, length)
+		return newArray(collection.Elements[start:end])
+	default:
+		return newError("argument to `slice` not supported, got %s", args[0].Type())
+	}
+}
+
+// rangeArgs reads the start and optional end arguments of substring and
+// slice; end defaults to length.
+func rangeArgs(name string, args []object.Object, length int64) (start, end int64, err *object.Error) {
+	if start, err = integerArg(name, args, 1); err != nil {
+		return 0, 0, err
+	}
+	end = length
+	if len(args) == 3 {
+		if end, err = integerArg(name, args, 2); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, end, nil
+}
+
+func clampRange(start, end, length int64) (int64, int64) {
+	clamp := func(i int64) int64 {
+		if i < 0 {
+			i += length
+		}
+		return min(max(i, 0), length)
+	}
+	start, end = clamp(start), clamp(end)
+	return start, max(start, end)
+}
+
+func builtInChars(args ...object.Object) object.Object {
+	if err := checkArity(args, 1, 1); err != nil {
+		return err
+	}
+	s, err := stringArg("chars", args, 0)
+	if err != nil {
+		return err
+	}
+	return stringArray(strings.Split(s, ""))
+}
+
+// builtInFormat formats its arguments printf-style. The verbs are %v and %s
+// for any value as puts would print it, %q for a quoted string, %d, %x,
+// %X, %o and %b for integers, %f, %e and %g for numbers, %t for booleans
+// and %% for a percent sign. Flags, width and precision work as in Go.
+func builtInFormat(args ...object.Object) object.Object {
+	if err := checkArity(args, 1, -1); err != nil {
+		return err
+	}
+	format, err := stringArg("format", args, 0)
+	if err != nil {
+		return err
+	}
+	values := args[1:]
+
+	var out strings.Builder
+	next := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		for i < len(format) && (format[i] >= '0' && format[i] <= '9' || format[i] == '.') {
+			i++
+		}
+		if i == len(format) {
+			return newError("format: missing verb at end of %q", format)
+		}
+
+		verb := format[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if next == len(values) {
+			return newError("format: missing argument for %%%c", verb)
+		}
+
+		value, err := formatValue(verb, values[next])
+		if err != nil {
+			return err
+		}
+		out.WriteString(fmt.Sprintf(format[start:i+1], value))
+		next++
+	}
+
+	if next != len(values) {
+		return newError("format: %d arguments given, %d used", len(values), next)
+	}
+	return &object.String{Value: out.String()}
+}
+
+// formatValue converts an argument of format to the Go value its verb
+// expects.
+func formatValue(verb byte, arg object.Object) (any, *object.Error) {
+	switch verb {
+	case 'v', 's', 'q':
+		return arg.Inspect(), nil
+	case 'd', 'x', 'X', 'o', 'b':
+		switch i := arg.(type) {
+		case *object.Integer:
+			return i.Value, nil
+		case *object.BigInt:
+			return i.Value, nil
+		}
+	case 'f', 'e', 'g':
+		if isNumber(arg) {
+			return toFloat(arg), nil
+		}
+	case 't':
+		if b, ok := arg.(*object.Boolean); ok {
+			return b.Value, nil
+		}
+	default:
+		return nil, newError("format: unknown verb %%%c", verb)
+	}
+	return nil, newError("format: %%%c does not accept %s", verb, arg.Type())
+}
+
+func stringArray(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for i, value := range values {
+		elements[i] = &object.String{Value: value}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	s := str.(*object.String).Value
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return NULL
+	}
+	i := integer.Value
+
+	if i >= 0 {
+		for _, r := range s {
+			if i == 0 {
+				return &object.String{Value: string(r)}
+			}
+			i--
+		}
+	}
+
+	return NULL
+}
+
+// checkArity reports a call with fewer than min or more than max
+// arguments. A negative max means any number of extra arguments.
+func checkArity(args []object.Object, min, max int) *object.Error {
+	if len(args) >= min && (max < 0 || len(args) <= max) {
+		return nil
+	}
+
+	return newError("wrong number of arguments. got=%d, want=%s", len(args), arity(min, max))
+}
+
+// arity describes how many arguments a function takes: "1", "1 or 2",
+// "1 to 3" or, when max is -1, "at least 1".
+func arity(min, max int) string {
+	want := strconv.Itoa(min)
+	switch {
+	case max < 0:
+		want = "at least " + want
+	case max == min+1:
+		want += " or " + strconv.Itoa(max)
+	case max > min:
+		want += " to " + strconv.Itoa(max)
+	}
+	return want
+}
+
+func stringArg(name string, args []object.Object, i int) (string, *object.Error) {
+	s, ok := args[i].(*object.String)
+	if !ok {
+		return "", newError("argument %d to `%s` must be STRING, got %s", i+1, name, args[i].Type())
+	}
+	return s.Value, nil
+}
+
+func integerArg(name string, args []object.Object, i int) (int64, *object.Error) {
+	n, ok := args[i].(*object.Integer)
+	if !ok && args[i].Type() == object.INTEGER_OBJ {
+		return 0, newError("argument %d to `%s` out of range: %s", i+1, name, args[i].Inspect())
+	}
+	if !ok {
+		return 0, newError("argument %d to `%s` must be INTEGER, got %s", i+1, name, args[i].Type())
+	}
+	return n.Value, nil
+}
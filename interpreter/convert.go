@@ -0,0 +1,151 @@
+package interpreter
+
+import (
+	"fmt"
+	"interpreter/evaluator"
+	"interpreter/object"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// ToObject converts a Go value to an object. It accepts nil, booleans,
+// integers including *big.Int, floats, strings, slices and arrays of
+// convertible values, maps with string keys (added to the hash in sorted
+// order), and values that already are objects.
+func ToObject(value any) (object.Object, error) {
+	switch value := value.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case object.Object:
+		return value, nil
+	case bool:
+		if value {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case string:
+		return &object.String{Value: value}, nil
+	case int64:
+		return &object.Integer{Value: value}, nil
+	case *big.Int:
+		return evaluator.NewInteger(new(big.Int).Set(value)), nil
+	case int:
+		return &object.Integer{Value: int64(value)}, nil
+	case float64:
+		return &object.Float{Value: value}, nil
+	}
+
+	return reflectToObject(reflect.ValueOf(value))
+}
+
+func reflectToObject(value reflect.Value) (object.Object, error) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: value.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return evaluator.NewInteger(new(big.Int).SetUint64(value.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: value.Float()}, nil
+	case reflect.Bool:
+		if value.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.String:
+		return &object.String{Value: value.String()}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			element, err := ToObject(value.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot convert %s: map keys must be strings", value.Type())
+		}
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+		hash := object.NewHash()
+		for _, key := range keys {
+			element, err := ToObject(value.MapIndex(key).Interface())
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(&object.String{Value: key.String()}, element)
+		}
+		return hash, nil
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return ToObject(value.Elem().Interface())
+	case reflect.Invalid:
+		return evaluator.NULL, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to an object", value.Type())
+}
+
+// ToGo converts an object to a Go value: int64 (or *big.Int for integers
+// beyond 64 bits), float64, string, bool, nil, []any or map[string]any.
+// Hash keys that are not strings are written the way the language prints
+// them. Functions and other objects are returned unchanged, and so is an
+// array or hash where it recurs inside itself.
+func ToGo(obj object.Object) any {
+	return toGo(obj, map[object.Object]bool{})
+}
+
+// toGo converts obj. open holds the arrays and hashes being converted
+// further up.
+func toGo(obj object.Object, open map[object.Object]bool) any {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.BigInt:
+		return new(big.Int).Set(obj.Value)
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		if open[obj] {
+			return obj
+		}
+		open[obj] = true
+		defer delete(open, obj)
+
+		elements := make([]any, 0, len(obj.Elements))
+		for _, element := range obj.Elements {
+			elements = append(elements, toGo(element, open))
+		}
+		return elements
+	case *object.Hash:
+		if open[obj] {
+			return obj
+		}
+		open[obj] = true
+		defer delete(open, obj)
+
+		values := make(map[string]any, obj.Len())
+		for _, pair := range obj.Ordered() {
+			key := pair.Key.Inspect()
+			if str, ok := pair.Key.(*object.String); ok {
+				key = str.Value
+			}
+			values[key] = toGo(pair.Value, open)
+		}
+		return values
+	default:
+		return obj
+	}
+}
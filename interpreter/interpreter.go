@@ -0,0 +1,228 @@
+// Package interpreter embeds the language in Go programs. An Interpreter
+// keeps its global environment between calls to Eval, so a host can load a
+// script once and then call the functions it defines.
+//
+// An Interpreter is not safe for concurrent use: its globals and its budget
+// are shared by every call, so it must not be used from more than one
+// goroutine at a time. Programs that run scripts in parallel should create
+// one Interpreter per goroutine, or serialize access to a shared one.
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"io"
+	"os"
+	"strings"
+)
+
+// Interpreter runs scripts in one global environment. Its methods must not
+// be called from several goroutines at once.
+type Interpreter struct {
+	env     *object.Environment
+	limits  object.Limits
+	running bool
+}
+
+type config struct {
+	limits   object.Limits
+	stdout   io.Writer
+	stderr   io.Writer
+	builtins map[string]*object.BuiltIn
+	globals  map[string]any
+	path     []string
+}
+
+// Option configures an Interpreter created by New.
+type Option func(*config)
+
+// WithStdout sends the output of puts to w instead of os.Stdout.
+func WithStdout(w io.Writer) Option {
+	return func(c *config) { c.stdout = w }
+}
+
+// WithStderr sends the output of eputs to w instead of os.Stderr.
+func WithStderr(w io.Writer) Option {
+	return func(c *config) { c.stderr = w }
+}
+
+// WithBuiltin makes a Go function callable from scripts under name. It
+// takes precedence over a standard builtin with the same name.
+func WithBuiltin(name string, fn object.BuiltInFunction) Option {
+	return func(c *config) { c.builtins[name] = &object.BuiltIn{Fn: fn} }
+}
+
+// WithHigherOrderBuiltin is like WithBuiltin for a Go function that calls
+// script functions passed to it, using the Caller it is given.
+func WithHigherOrderBuiltin(name string, fn object.HigherOrderFunction) Option {
+	return func(c *config) { c.builtins[name] = &object.BuiltIn{HigherOrder: fn} }
+}
+
+// WithLimits bounds the steps, call depth and allocations of each call to
+// Eval or Call.
+func WithLimits(limits object.Limits) Option {
+	return func(c *config) { c.limits = limits }
+}
+
+// WithGlobal defines a global variable before any script runs. The value
+// is converted with ToObject.
+func WithGlobal(name string, value any) Option {
+	return func(c *config) { c.globals[name] = value }
+}
+
+// WithModulePath adds dirs to the directories searched for imported
+// modules that are not found next to the importing script.
+func WithModulePath(dirs ...string) Option {
+	return func(c *config) { c.path = append(c.path, dirs...) }
+}
+
+func New(options ...Option) (*Interpreter, error) {
+	c := &config{
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
+		builtins: map[string]*object.BuiltIn{},
+		globals:  map[string]any{},
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	// Host builtins live in a prelude around the global environment, where
+	// imported modules can see them too.
+	prelude := object.NewEnvironment()
+	prelude.Set("puts", evaluator.Printer(c.stdout))
+	prelude.Set("eputs", evaluator.Printer(c.stderr))
+
+	for name, builtin := range c.builtins {
+		prelude.Set(name, builtin)
+	}
+
+	prelude.Modules().Prelude = prelude
+	prelude.Modules().SearchPath = c.path
+
+	interp := &Interpreter{env: object.NewEnclosedEnvironment(prelude), limits: c.limits}
+
+	for name, value := range c.globals {
+		if err := interp.Set(name, value); err != nil {
+			return nil, fmt.Errorf("global %s: %w", name, err)
+		}
+	}
+
+	return interp, nil
+}
+
+// Eval parses and runs src in the interpreter's global environment and
+// returns the value of its last statement.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	return i.EvalFile(context.Background(), "", src)
+}
+
+// EvalContext is like Eval, but stops the script with a Canceled or
+// DeadlineExceeded error when ctx ends.
+func (i *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	return i.EvalFile(ctx, "", src)
+}
+
+// EvalFile is like EvalContext, but positions in errors name filename.
+func (i *Interpreter) EvalFile(ctx context.Context, filename, src string) (object.Object, error) {
+	par := parser.New(lexer.NewFile(filename, src))
+	program := par.ParseProgram()
+	if len(par.Errors()) != 0 {
+		return nil, &ParseError{Messages: par.Errors()}
+	}
+
+	defer i.start(ctx)()
+	return result(evaluator.Eval(program, i.env))
+}
+
+// Call calls the script function or builtin bound to name. The arguments
+// are converted with ToObject.
+func (i *Interpreter) Call(name string, args ...any) (object.Object, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is like Call, but stops the function when ctx ends.
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...any) (object.Object, error) {
+	fn, ok := i.env.Get(name)
+	if !ok {
+		builtin, found := evaluator.LookupBuiltin(name)
+		if !found {
+			return nil, fmt.Errorf("identifier not found: %s", name)
+		}
+		fn = builtin
+	}
+
+	arguments := make([]object.Object, 0, len(args))
+	for _, arg := range args {
+		converted, err := ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, converted)
+	}
+
+	defer i.start(ctx)()
+	return result(evaluator.ApplyFunction(i.env.Meter(), fn, arguments))
+}
+
+// start gives a run a fresh budget and returns the function that ends it.
+// A builtin that calls back into the interpreter runs within the budget of
+// the script that called it.
+func (i *Interpreter) start(ctx context.Context) func() {
+	if i.running {
+		return func() {}
+	}
+
+	i.running = true
+	i.env.Meter().Reset(ctx, i.limits)
+	return func() { i.running = false }
+}
+
+// Get returns the global bound to name.
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// Set binds name to value, converted with ToObject, in the global
+// environment.
+func (i *Interpreter) Set(name string, value any) error {
+	converted, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+	i.env.Set(name, converted)
+	return nil
+}
+
+func result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Err: errObj}
+	}
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+	return obj, nil
+}
+
+// ParseError reports the syntax errors that kept a script from running.
+type ParseError struct {
+	Messages []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Messages, "\n")
+}
+
+// RuntimeError wraps the error object a script stopped with. Err.Kind
+// tells which limit, if any, stopped it.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Inspect()
+}
@@ -0,0 +1,339 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"interpreter/object"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvalKeepsGlobals(t *testing.T) {
+	interp, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := interp.Eval("let double = fn(x) { x * 2 }; let limit = 10;"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := interp.Eval("double(limit)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ToGo(result) != int64(20) {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	limit, ok := interp.Get("limit")
+	if !ok || ToGo(limit) != int64(10) {
+		t.Errorf("Get returned %v, %t", limit, ok)
+	}
+
+	if _, ok := interp.Get("missing"); ok {
+		t.Errorf("Get found an undefined name")
+	}
+}
+
+func TestCall(t *testing.T) {
+	interp, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = interp.Eval(`let allow = fn(user) { user["age"] > 17 }; let greet = fn(name) { "Hello " <> name }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := interp.Call("allow", map[string]any{"name": "Ann", "age": 42})
+	if err != nil || ToGo(result) != true {
+		t.Errorf("allow: got %v, %v", result, err)
+	}
+
+	result, err = interp.Call("greet", "World")
+	if err != nil || ToGo(result) != "Hello World" {
+		t.Errorf("greet: got %v, %v", result, err)
+	}
+
+	result, err = interp.Call("len", []string{"a", "b", "c"})
+	if err != nil || ToGo(result) != int64(3) {
+		t.Errorf("len: got %v, %v", result, err)
+	}
+
+	if _, err := interp.Call("greet"); err == nil || !strings.Contains(err.Error(), "wrong number of arguments") {
+		t.Errorf("expected an arity error. got=%v", err)
+	}
+
+	if _, err := interp.Call("nothing"); err == nil || err.Error() != "identifier not found: nothing" {
+		t.Errorf("expected identifier not found. got=%v", err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	var logged []string
+
+	interp, err := New(
+		WithStdout(&stdout),
+		WithStderr(&stderr),
+		WithGlobal("config", map[string]any{"retries": 3, "hosts": []any{"a", "b"}}),
+		WithBuiltin("log", func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				logged = append(logged, arg.Inspect())
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = interp.Eval(`puts(config["retries"]); eputs(config["hosts"][1]); log("done")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stdout.String() != "3\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+	if stderr.String() != "b\n" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+	if !reflect.DeepEqual(logged, []string{"done"}) {
+		t.Errorf("builtin not called. got=%v", logged)
+	}
+
+	if _, err := New(WithGlobal("bad", map[int]string{1: "x"})); err == nil {
+		t.Errorf("expected an error for a map with integer keys")
+	}
+}
+
+func TestBuiltinReturningFalse(t *testing.T) {
+	no := func(args ...object.Object) object.Object {
+		return &object.Boolean{Value: false}
+	}
+	interp, err := New(WithBuiltin("no", no))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`if (no()) { 1 } else { 2 }`, int64(2)},
+		{`!no()`, true},
+		{`no() || "default"`, "default"},
+		{`filter([1, 2], fn(x) { no() })`, []any{}},
+		{`let n = 0; while (no()) { n += 1 }; n`, int64(0)},
+	}
+
+	for _, tt := range tests {
+		result, err := interp.Eval(tt.input)
+		if err != nil {
+			t.Errorf("%s: %s", tt.input, err)
+			continue
+		}
+		if got := ToGo(result); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: want=%#v, got=%#v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	interp, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = interp.EvalFile(context.Background(), "rules.mk", "let x = ;")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a ParseError. got=%v", err)
+	}
+	if !strings.HasPrefix(parseErr.Messages[0], "rules.mk:1:9:") {
+		t.Errorf("wrong parse error. got=%q", parseErr.Messages[0])
+	}
+
+	_, err = interp.Eval("1 + true")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a RuntimeError. got=%v", err)
+	}
+	if runtimeErr.Err.Message != "Type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong runtime error. got=%q", runtimeErr.Err.Message)
+	}
+
+	_, err = interp.Eval("let n = 0; 1 / n")
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Message != "division by zero: 1 / 0" {
+		t.Errorf("expected a division by zero error. got=%v", err)
+	}
+}
+
+func TestConversion(t *testing.T) {
+	type name string
+	type flag bool
+
+	tests := []struct {
+		input    any
+		expected any
+	}{
+		{nil, nil},
+		{true, true},
+		{int64(7), int64(7)},
+		{int32(-3), int64(-3)},
+		{uint8(200), int64(200)},
+		{2.5, 2.5},
+		{float32(0.5), 0.5},
+		{"text", "text"},
+		{name("bob"), "bob"},
+		{flag(false), false},
+		{[]name{"a", "b"}, []any{"a", "b"}},
+		{[]any{1, "two", []int{3}}, []any{int64(1), "two", []any{int64(3)}}},
+		{map[string]any{"a": 1, "b": map[string]bool{"c": true}}, map[string]any{"a": int64(1), "b": map[string]any{"c": true}}},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%v): %s", tt.input, err)
+			continue
+		}
+
+		if back := ToGo(obj); !reflect.DeepEqual(back, tt.expected) {
+			t.Errorf("round trip of %v. want=%#v, got=%#v", tt.input, tt.expected, back)
+		}
+	}
+
+	obj, err := ToObject(uint64(1) << 63)
+	if err != nil {
+		t.Fatalf("ToObject(1 << 63): %s", err)
+	}
+	if n, ok := ToGo(obj).(*big.Int); !ok || n.String() != "9223372036854775808" {
+		t.Errorf("round trip of 1 << 63. got=%#v", ToGo(obj))
+	}
+	if obj, _ := ToObject(big.NewInt(5)); ToGo(obj) != int64(5) {
+		t.Errorf("a *big.Int that fits should become an int64. got=%#v", ToGo(obj))
+	}
+	if _, err := ToObject(struct{}{}); err == nil {
+		t.Errorf("expected an error for a struct")
+	}
+
+	cyclic := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}}}
+	cyclic.Elements = append(cyclic.Elements, cyclic)
+	if converted, ok := ToGo(cyclic).([]any); !ok || len(converted) != 2 || converted[1] != cyclic {
+		t.Errorf("an array inside itself should be left unchanged. got=%#v", ToGo(cyclic))
+	}
+	hash := object.NewHash()
+	hash.Set(&object.String{Value: "self"}, hash)
+	if converted, ok := ToGo(hash).(map[string]any); !ok || converted["self"] != hash {
+		t.Errorf("a hash inside itself should be left unchanged. got=%#v", ToGo(hash))
+	}
+}
+
+func TestLimits(t *testing.T) {
+	interp, err := New(WithLimits(object.Limits{MaxSteps: 10000}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = interp.Eval("while (true) { }")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != object.StepLimitExceeded {
+		t.Fatalf("expected the step limit to stop the loop. got=%v", err)
+	}
+
+	// Every call gets a fresh budget.
+	if _, err := interp.Eval("let total = 0; for (i in [1, 2, 3]) { total += i } total"); err != nil {
+		t.Errorf("budget was not reset: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	unlimited, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = unlimited.EvalContext(ctx, "while (true) { }")
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != object.DeadlineExceeded {
+		t.Errorf("expected the deadline to stop the loop. got=%v", err)
+	}
+
+	// A builtin that ignores the error from a function it calls does not
+	// give the script a fresh budget.
+	ignore := func(call object.Caller, args ...object.Object) object.Object {
+		call(args[0])
+		return nil
+	}
+	stubborn, err := New(WithHigherOrderBuiltin("ignore", ignore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stubborn.Eval("let f = fn() { 1 + f() }; while (true) { ignore(f) }")
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != object.CallDepthExceeded {
+		t.Errorf("expected the call depth limit to stop the outer loop. got=%v", err)
+	}
+
+	// catch must not swallow the deadline.
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = unlimited.EvalContext(ctx, "while (true) { try { while (true) { } } catch (e) { } }")
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != object.DeadlineExceeded {
+		t.Errorf("expected the deadline to stop the loop inside try. got=%v", err)
+	}
+}
+
+func TestModules(t *testing.T) {
+	dir := t.TempDir()
+	lib := `export let shout = fn(s) { puts(caps(s)) };`
+	if err := os.WriteFile(filepath.Join(dir, "loud.mk"), []byte(lib), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	caps := func(args ...object.Object) object.Object {
+		return &object.String{Value: strings.ToUpper(args[0].Inspect())}
+	}
+	interp, err := New(WithModulePath(dir), WithStdout(&stdout), WithBuiltin("caps", caps))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := interp.Eval(`import { shout } from "loud"; shout("hi")`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if stdout.String() != "HI\n" {
+		t.Errorf("wrong output. got=%q", stdout.String())
+	}
+
+	if _, ok := interp.Get("caps"); !ok {
+		t.Errorf("host builtin is not visible to the program")
+	}
+}
+
+func TestHigherOrderBuiltin(t *testing.T) {
+	twice := func(call object.Caller, args ...object.Object) object.Object {
+		return call(args[0], call(args[0], args[1]))
+	}
+	interp, err := New(WithHigherOrderBuiltin("twice", twice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := interp.Eval(`twice(fn(x) { x * 3 }, 2)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ToGo(result) != int64(18) {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
@@ -1,20 +1,35 @@
 package lexer
 
-import "interpreter/token"
+import (
+	"interpreter/token"
+)
 
 type Lexer struct {
 	input        string
+	filename     string
 	position     int
 	nextPosition int
 	char         byte
+	line         int
+	column       int
+	comments     []token.Token
+	errors       []Error
 }
 
 func New(input string) *Lexer {
+	return NewFile("", input)
+}
+
+// NewFile returns a lexer whose token positions are reported against filename.
+func NewFile(filename string, input string) *Lexer {
 	lexer := &Lexer{
 		input:        input,
+		filename:     filename,
 		position:     0,
 		nextPosition: 0,
 		char:         0,
+		line:         1,
+		column:       0,
 	}
 	lexer.readChar()
 	return lexer
@@ -23,7 +38,8 @@ func New(input string) *Lexer {
 func (lexer *Lexer) NextToken() token.Token {
 	var nextToken token.Token
 
-	lexer.skipWhitespace()
+	lexer.skipWhitespaceAndComments()
+	position := lexer.currentPosition()
 
 	switch lexer.char {
 	case '(':
@@ -50,9 +66,17 @@ func (lexer *Lexer) NextToken() token.Token {
 			nextToken = newToken(token.ASSIGN, lexer.char)
 		}
 	case '+':
-		nextToken = newToken(token.PLUS, lexer.char)
+		if lexer.peekChar() == '=' {
+			nextToken = lexer.readTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			nextToken = newToken(token.PLUS, lexer.char)
+		}
 	case '-':
-		nextToken = newToken(token.MINUS, lexer.char)
+		if lexer.peekChar() == '=' {
+			nextToken = lexer.readTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			nextToken = newToken(token.MINUS, lexer.char)
+		}
 	case '!':
 		if lexer.peekChar() == '=' {
 			firstChar := lexer.char
@@ -65,26 +89,74 @@ func (lexer *Lexer) NextToken() token.Token {
 			nextToken = newToken(token.BANG, lexer.char)
 		}
 	case '/':
-		nextToken = newToken(token.SLASH, lexer.char)
+		if lexer.peekChar() == '=' {
+			nextToken = lexer.readTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			nextToken = newToken(token.SLASH, lexer.char)
+		}
 	case '*':
-		nextToken = newToken(token.ASTERISK, lexer.char)
+		if lexer.peekChar() == '=' {
+			nextToken = lexer.readTwoCharToken(token.ASTERISK_ASSIGN)
+		} else if lexer.peekChar() == '*' {
+			nextToken = lexer.readTwoCharToken(token.POWER)
+		} else {
+			nextToken = newToken(token.ASTERISK, lexer.char)
+		}
 	case '<':
-		if lexer.peekChar() == '>' {
+		if lexer.peekChar() == '>' && lexer.peekCharAt(2) == '=' {
+			lexer.readChar()
+			lexer.readChar()
+			nextToken = token.Token{Type: token.LTGT_ASSIGN, Literal: "<>="}
+		} else if lexer.peekChar() == '>' {
 			firstChar := lexer.char
 			lexer.readChar()
 			nextToken = token.Token{
 				Type:    token.LTGT,
 				Literal: string(firstChar) + string(lexer.char),
 			}
+		} else if lexer.peekChar() == '=' {
+			nextToken = lexer.readTwoCharToken(token.LT_EQ)
+		} else if lexer.peekChar() == '<' {
+			nextToken = lexer.readTwoCharToken(token.SHIFT_LEFT)
 		} else {
 			nextToken = newToken(token.LT, lexer.char)
 		}
 	case '>':
-		nextToken = newToken(token.GT, lexer.char)
+		if lexer.peekChar() == '=' {
+			nextToken = lexer.readTwoCharToken(token.GT_EQ)
+		} else if lexer.peekChar() == '>' {
+			nextToken = lexer.readTwoCharToken(token.SHIFT_RIGHT)
+		} else {
+			nextToken = newToken(token.GT, lexer.char)
+		}
+	case '%':
+		nextToken = newToken(token.PERCENT, lexer.char)
+	case '^':
+		nextToken = newToken(token.CARET, lexer.char)
+	case '&':
+		if lexer.peekChar() == '&' {
+			nextToken = lexer.readTwoCharToken(token.AND)
+		} else {
+			nextToken = newToken(token.AMPERSAND, lexer.char)
+		}
+	case '|':
+		if lexer.peekChar() == '|' {
+			nextToken = lexer.readTwoCharToken(token.OR)
+		} else {
+			nextToken = newToken(token.PIPE, lexer.char)
+		}
 	case ';':
 		nextToken = newToken(token.SEMICOLON, lexer.char)
 	case ',':
 		nextToken = newToken(token.COMMA, lexer.char)
+	case '.':
+		if lexer.peekChar() == '.' && lexer.peekCharAt(2) == '.' {
+			lexer.readChar()
+			lexer.readChar()
+			nextToken = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			nextToken = newToken(token.DOT, lexer.char)
+		}
 	case '"':
 		nextToken.Type = token.STRING
 		nextToken.Literal = lexer.readString()
@@ -98,10 +170,11 @@ func (lexer *Lexer) NextToken() token.Token {
 			literal := lexer.readIdentifier()
 			nextToken.Literal = literal
 			nextToken.Type = token.LookupIdentifier(literal)
+			nextToken.Position = position
 			return nextToken
 		} else if isDigit(lexer.char) {
-			nextToken.Literal = lexer.readNumber()
-			nextToken.Type = token.INT
+			nextToken.Literal, nextToken.Type = lexer.readNumber()
+			nextToken.Position = position
 			return nextToken
 		} else {
 			nextToken = newToken(token.ILLEGAL, lexer.char)
@@ -109,6 +182,7 @@ func (lexer *Lexer) NextToken() token.Token {
 	}
 
 	lexer.readChar()
+	nextToken.Position = position
 	return nextToken
 }
 
@@ -132,13 +206,44 @@ func (lexer *Lexer) readIdentifier() string {
 	return word
 }
 
-func (lexer *Lexer) readNumber() string {
+// readNumber reads an integer or a float such as 3.14, 1e9 or 2.5E-3. A
+// fraction needs a digit after the dot and an exponent needs at least one
+// digit, otherwise the number ends before them.
+func (lexer *Lexer) readNumber() (string, token.TokenType) {
 	startPosition := lexer.position
-	for isDigit(lexer.char) {
+	tokenType := token.TokenType(token.INT)
+
+	lexer.readDigits()
+
+	if lexer.char == '.' && isDigit(lexer.peekChar()) {
+		tokenType = token.FLOAT
 		lexer.readChar()
+		lexer.readDigits()
+	}
+
+	if lexer.char == 'e' || lexer.char == 'E' {
+		exponentLength := 1
+		if next := lexer.peekCharAt(1); next == '+' || next == '-' {
+			exponentLength = 2
+		}
+
+		if isDigit(lexer.peekCharAt(exponentLength)) {
+			tokenType = token.FLOAT
+			for i := 0; i < exponentLength; i++ {
+				lexer.readChar()
+			}
+			lexer.readDigits()
+		}
 	}
+
 	number := lexer.input[startPosition:lexer.position]
-	return number
+	return number, tokenType
+}
+
+func (lexer *Lexer) readDigits() {
+	for isDigit(lexer.char) {
+		lexer.readChar()
+	}
 }
 
 func isLetter(char byte) bool {
@@ -149,12 +254,100 @@ func isDigit(char byte) bool {
 	return char >= '0' && char <= '9'
 }
 
+// Comments returns the comments skipped so far, in source order. They are
+// kept as trivia for tools such as formatters rather than emitted as tokens.
+func (lexer *Lexer) Comments() []token.Token {
+	return lexer.comments
+}
+
+// Error is a problem in the source text that the lexer could not turn into
+// tokens, such as a comment that is never closed.
+type Error struct {
+	Position token.Position
+	Message  string
+}
+
+func (e Error) String() string {
+	return e.Position.String() + ": " + e.Message
+}
+
+func (lexer *Lexer) Errors() []Error {
+	return lexer.errors
+}
+
+func (lexer *Lexer) skipWhitespaceAndComments() {
+	for {
+		lexer.skipWhitespace()
+
+		if lexer.char == '/' && lexer.peekChar() == '/' {
+			lexer.readLineComment()
+		} else if lexer.char == '/' && lexer.peekChar() == '*' {
+			lexer.readBlockComment()
+		} else {
+			return
+		}
+	}
+}
+
+func (lexer *Lexer) readLineComment() {
+	position := lexer.currentPosition()
+	for lexer.char != '\n' && lexer.char != 0 {
+		lexer.readChar()
+	}
+	lexer.addComment(position)
+}
+
+// Block comments nest, so /* a /* b */ c */ is a single comment.
+func (lexer *Lexer) readBlockComment() {
+	position := lexer.currentPosition()
+	depth := 0
+
+	for {
+		switch {
+		case lexer.char == 0:
+			lexer.addComment(position)
+			lexer.errors = append(lexer.errors, Error{Position: position, Message: "Unterminated block comment."})
+			return
+		case lexer.char == '/' && lexer.peekChar() == '*':
+			depth += 1
+			lexer.readChar()
+		case lexer.char == '*' && lexer.peekChar() == '/':
+			depth -= 1
+			lexer.readChar()
+			if depth == 0 {
+				lexer.readChar()
+				lexer.addComment(position)
+				return
+			}
+		}
+		lexer.readChar()
+	}
+}
+
+func (lexer *Lexer) addComment(position token.Position) {
+	lexer.comments = append(lexer.comments, token.Token{
+		Type:     token.COMMENT,
+		Literal:  lexer.input[position.Offset:lexer.position],
+		Position: position,
+	})
+}
+
 func (lexer *Lexer) skipWhitespace() {
 	for lexer.char == ' ' || lexer.char == '\t' || lexer.char == '\n' || lexer.char == '\r' {
 		lexer.readChar()
 	}
 }
 
+// readTwoCharToken consumes the current and the next character as one token.
+func (lexer *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+	firstChar := lexer.char
+	lexer.readChar()
+	return token.Token{
+		Type:    tokenType,
+		Literal: string(firstChar) + string(lexer.char),
+	}
+}
+
 func newToken(tokenType token.TokenType, char byte) token.Token {
 	return token.Token{
 		Type:    tokenType,
@@ -163,6 +356,12 @@ func newToken(tokenType token.TokenType, char byte) token.Token {
 }
 
 func (lexer *Lexer) readChar() {
+	if lexer.char == '\n' {
+		lexer.line += 1
+		lexer.column = 0
+	}
+	lexer.column += 1
+
 	if lexer.nextPosition >= len(lexer.input) {
 		lexer.char = 0
 	} else {
@@ -173,9 +372,24 @@ func (lexer *Lexer) readChar() {
 }
 
 func (lexer *Lexer) peekChar() byte {
-	if lexer.nextPosition >= len(lexer.input) {
+	return lexer.peekCharAt(1)
+}
+
+// peekCharAt looks distance characters ahead of the current one.
+func (lexer *Lexer) peekCharAt(distance int) byte {
+	position := lexer.position + distance
+	if position >= len(lexer.input) {
 		return 0
 	} else {
-		return lexer.input[lexer.nextPosition]
+		return lexer.input[position]
+	}
+}
+
+func (lexer *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: lexer.filename,
+		Line:     lexer.line,
+		Column:   lexer.column,
+		Offset:   lexer.position,
 	}
 }
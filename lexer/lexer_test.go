@@ -13,7 +13,7 @@ func TestNextToken(t *testing.T) {
 		x + y;
 		};
 		let result = add(five, ten);
-		!-/*5;
+		!-/ *5;
 		5 < 10 > 5;
 
 		if (5 < 10) {
@@ -28,6 +28,14 @@ func TestNextToken(t *testing.T) {
 		"foo bar"
 		[1, 2];
 		{"foo": "bar"}
+		while for in break continue
+		try catch finally throw
+		import export lib.name
+		+= -= *= /= <>= <> <
+		<= >= > =
+		&& || & | ^ % ** * << >>
+		f(...xs) a.b
+		7 div 2
 	`
 
 	tests := []struct {
@@ -120,6 +128,209 @@ func TestNextToken(t *testing.T) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.TRY, "try"},
+		{token.CATCH, "catch"},
+		{token.FINALLY, "finally"},
+		{token.THROW, "throw"},
+		{token.IMPORT, "import"},
+		{token.EXPORT, "export"},
+		{token.IDENTIFIER, "lib"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "name"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.MINUS_ASSIGN, "-="},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.SLASH_ASSIGN, "/="},
+		{token.LTGT_ASSIGN, "<>="},
+		{token.LTGT, "<>"},
+		{token.LT, "<"},
+		{token.LT_EQ, "<="},
+		{token.GT_EQ, ">="},
+		{token.GT, ">"},
+		{token.ASSIGN, "="},
+		{token.AND, "&&"},
+		{token.OR, "||"},
+		{token.AMPERSAND, "&"},
+		{token.PIPE, "|"},
+		{token.CARET, "^"},
+		{token.PERCENT, "%"},
+		{token.POWER, "**"},
+		{token.ASTERISK, "*"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.IDENTIFIER, "f"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENTIFIER, "xs"},
+		{token.RPAREN, ")"},
+		{token.IDENTIFIER, "a"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "b"},
+		{token.INT, "7"},
+		{token.DIV, "div"},
+		{token.INT, "2"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x + 10;\n\"str\""
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+		expectedOffset int
+	}{
+		{token.LET, 1, 1, 0},
+		{token.IDENTIFIER, 1, 5, 4},
+		{token.ASSIGN, 1, 7, 6},
+		{token.INT, 1, 9, 8},
+		{token.SEMICOLON, 1, 10, 9},
+		{token.IDENTIFIER, 2, 3, 13},
+		{token.PLUS, 2, 5, 15},
+		{token.INT, 2, 7, 17},
+		{token.SEMICOLON, 2, 9, 19},
+		{token.STRING, 3, 1, 21},
+		{token.EOF, 3, 6, 26},
+	}
+
+	l := NewFile("test.mk", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Position.Filename != "test.mk" {
+			t.Errorf("tests[%d] - filename wrong. expected=%q, got=%q", i, "test.mk", tok.Position.Filename)
+		}
+
+		if tok.Position.Line != tt.expectedLine || tok.Position.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i,
+				tt.expectedLine, tt.expectedColumn, tok.Position.Line, tok.Position.Column)
+		}
+
+		if tok.Position.Offset != tt.expectedOffset {
+			t.Errorf("tests[%d] - offset wrong. expected=%d, got=%d", i, tt.expectedOffset, tok.Position.Offset)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing comment
+/* block
+   comment */ x /* outer /* nested */ still outer */ + 1;
+a / b // division is not a comment`
+
+	expectedTokens := []token.TokenType{
+		token.LET, token.IDENTIFIER, token.ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENTIFIER, token.PLUS, token.INT, token.SEMICOLON,
+		token.IDENTIFIER, token.SLASH, token.IDENTIFIER,
+		token.EOF,
+	}
+
+	l := New(input)
+	for i, expected := range expectedTokens {
+		tok := l.NextToken()
+		if tok.Type != expected {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, expected, tok.Type)
+		}
+	}
+
+	expectedComments := []struct {
+		literal string
+		line    int
+		column  int
+	}{
+		{"// leading comment", 1, 1},
+		{"// trailing comment", 2, 12},
+		{"/* block\n   comment */", 3, 1},
+		{"/* outer /* nested */ still outer */", 4, 17},
+		{"// division is not a comment", 5, 7},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(expectedComments), len(comments))
+	}
+
+	for i, expected := range expectedComments {
+		comment := comments[i]
+		if comment.Type != token.COMMENT {
+			t.Errorf("comments[%d] - tokentype wrong. expected=%q, got=%q", i, token.COMMENT, comment.Type)
+		}
+		if comment.Literal != expected.literal {
+			t.Errorf("comments[%d] - literal wrong. expected=%q, got=%q", i, expected.literal, comment.Literal)
+		}
+		if comment.Position.Line != expected.line || comment.Position.Column != expected.column {
+			t.Errorf("comments[%d] - position wrong. expected=%d:%d, got=%d:%d", i,
+				expected.line, expected.column, comment.Position.Line, comment.Position.Column)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("let x = 1;\n/* open /* nested */ never closed")
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. expected=1, got=%d", len(errors))
+	}
+
+	if errors[0].String() != "2:1: Unterminated block comment." {
+		t.Errorf("wrong error. got=%q", errors[0].String())
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `3.14 42 1e9 2.5E-3 6e+2 7. 8e x.1`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.INT, "42"},
+		{token.FLOAT, "1e9"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "6e+2"},
+		{token.INT, "7"},
+		{token.DOT, "."},
+		{token.INT, "8"},
+		{token.IDENTIFIER, "e"},
+		{token.IDENTIFIER, "x"},
+		{token.DOT, "."},
+		{token.INT, "1"},
 		{token.EOF, ""},
 	}
 
@@ -1,18 +1,212 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"interpreter/ast"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
 	"interpreter/repl"
+	"interpreter/vm"
+	"io"
 	"os"
 	"os/user"
+	"path/filepath"
 )
 
+const (
+	exitOK           = 0
+	exitRuntimeError = 1
+	exitParseError   = 2
+	exitUsage        = 64
+)
+
+const usage = `Usage:
+  interpreter                      start the REPL (or run stdin if it is not a terminal)
+  interpreter run <file> [args...] run a script file ("-" reads stdin)
+  interpreter -e <expr> [args...]  evaluate an expression and print the result
+
+Options:
+  -engine eval|vm                  run scripts and -e expressions with the tree-walking
+                                   evaluator (default) or compile them to bytecode for
+                                   the virtual machine; the REPL always uses the evaluator
+  -path <dirs>                     directories searched for imported modules, separated
+                                   like PATH
+
+Script arguments are available to the program as the array ` + "`args`" + `.
+`
+
 func main() {
-	user, err := user.Current()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(arguments []string, in io.Reader, out, errOut io.Writer) int {
+	flags := flag.NewFlagSet("interpreter", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	flags.Usage = func() { io.WriteString(errOut, usage) }
+	expression := flags.String("e", "", "evaluate `expr` and print the result")
+	engine := flags.String("engine", "eval", "execution `engine`: eval or vm")
+	modulePath := flags.String("path", "", "module search `dirs`")
+
+	if err := flags.Parse(arguments); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if *engine != "eval" && *engine != "vm" {
+		fmt.Fprintf(errOut, "unknown engine %q\n", *engine)
+		flags.Usage()
+		return exitUsage
+	}
+
+	rest := flags.Args()
+	searchPath := filepath.SplitList(*modulePath)
+
+	if isFlagSet(flags, "e") {
+		return runSource("-e", *expression, rest, searchPath, *engine, out, errOut, true)
+	}
+
+	if len(rest) > 0 && rest[0] == "run" {
+		if len(rest) < 2 {
+			flags.Usage()
+			return exitUsage
+		}
+		return runFile(rest[1], rest[2:], searchPath, *engine, in, out, errOut)
+	}
+
+	if len(rest) > 0 {
+		fmt.Fprintf(errOut, "unknown command %q\n", rest[0])
+		flags.Usage()
+		return exitUsage
+	}
+
+	if file, ok := in.(*os.File); ok && !isTerminal(file) {
+		return runFile("-", nil, searchPath, *engine, in, out, errOut)
+	}
+
+	greet(out)
+	repl.Start(in, out)
+	return exitOK
+}
+
+func runFile(filename string, scriptArgs, searchPath []string, engine string, in io.Reader, out, errOut io.Writer) int {
+	var source []byte
+	var err error
+
+	if filename == "-" {
+		source, err = io.ReadAll(in)
+		filename = "<stdin>"
+	} else {
+		source, err = os.ReadFile(filename)
+	}
+
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return exitRuntimeError
+	}
+
+	return runSource(filename, string(source), scriptArgs, searchPath, engine, out, errOut, false)
+}
+
+func runSource(filename, source string, scriptArgs, searchPath []string, engine string, out, errOut io.Writer, printResult bool) int {
+	par := parser.New(lexer.NewFile(filename, source))
+	program := par.ParseProgram()
+	for _, diagnostic := range par.Diagnostics() {
+		io.WriteString(errOut, diagnostic.String()+"\n")
+	}
+	if len(par.Errors()) != 0 {
+		return exitParseError
+	}
+
+	var evaluated object.Object
+	if engine == "vm" {
+		var err error
+		evaluated, err = runBytecode(program, scriptArgs, out, errOut)
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			return exitParseError
+		}
+	} else {
+		// puts and eputs live in a prelude, where imported modules see
+		// them too.
+		prelude := object.NewEnvironment()
+		prelude.Set("puts", evaluator.Printer(out))
+		prelude.Set("eputs", evaluator.Printer(errOut))
+		prelude.Modules().Prelude = prelude
+		prelude.Modules().SearchPath = searchPath
+
+		env := object.NewEnclosedEnvironment(prelude)
+		env.Set("args", scriptArguments(scriptArgs))
+		evaluated = evaluator.Eval(program, env)
+	}
+
+	if errObj, ok := evaluated.(*object.Error); ok {
+		io.WriteString(errOut, errObj.Traceback()+"\n")
+		return exitRuntimeError
+	}
+
+	if printResult && evaluated != nil && evaluated != evaluator.NULL {
+		io.WriteString(out, evaluated.Inspect()+"\n")
+	}
+
+	return exitOK
+}
+
+func runBytecode(program *ast.Program, scriptArgs []string, out, errOut io.Writer) (object.Object, error) {
+	symbolTable := compiler.NewSymbolTable()
+	argsSymbol := symbolTable.Define("args")
+	putsSymbol := symbolTable.Define("puts")
+	eputsSymbol := symbolTable.Define("eputs")
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	globals := make([]object.Object, vm.GlobalsSize)
+	globals[argsSymbol.Index] = scriptArguments(scriptArgs)
+	globals[putsSymbol.Index] = evaluator.Printer(out)
+	globals[eputsSymbol.Index] = evaluator.Printer(errOut)
+
+	return vm.NewWithGlobalsStore(comp.Bytecode(), globals).Run(), nil
+}
+
+func scriptArguments(scriptArgs []string) *object.Array {
+	elements := make([]object.Object, 0, len(scriptArgs))
+	for _, arg := range scriptArgs {
+		elements = append(elements, &object.String{Value: arg})
+	}
+	return &object.Array{Elements: elements}
+}
+
+func greet(out io.Writer) {
+	name := "there"
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	fmt.Fprintf(out, "Hello %s! This is an interpreter for a to-be-named programming language!\n", name)
+	fmt.Fprintf(out, "Feel free to type in commands\n")
+}
+
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
 	if err != nil {
-		panic(err)
+		return false
 	}
-	fmt.Printf("Hello %s! This is an interpreter for a to-be-named programming language!\n", user.Username)
-	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	return info.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunExpression(t *testing.T) {
+	tests := []struct {
+		arguments      []string
+		expectedOut    string
+		expectedErrOut string
+		expectedStatus int
+	}{
+		{[]string{"-e", "1 + 2"}, "3\n", "", exitOK},
+		{[]string{"-e", "len(args)", "a", "b"}, "2\n", "", exitOK},
+		{[]string{"-e", "args[1]", "a", "b"}, "b\n", "", exitOK},
+		{[]string{"-e", "let x = 1;"}, "", "", exitOK},
+		{[]string{"-e", "1 + true"}, "", "Error: -e:1:3: Type mismatch: INTEGER + BOOLEAN\n", exitRuntimeError},
+		{[]string{"-e", "let = 1"}, "", "-e:1:5: Expected IDENTIFIER, got = instead.\n", exitParseError},
+		{[]string{"-engine", "vm", "-e", "args[1] <> args[0]", "a", "b"}, "ba\n", "", exitOK},
+		{[]string{"-engine", "vm", "-e", "let f = fn(x) { x + true }; f(1)"}, "", "Error: -e:1:19: Type mismatch: INTEGER + BOOLEAN\n", exitRuntimeError},
+		{[]string{"-e", "let f = fn() { 1 + true }; f()"}, "", "Error: -e:1:18: Type mismatch: INTEGER + BOOLEAN\n  at f (called at -e:1:29)\n", exitRuntimeError},
+		{[]string{"-engine", "jit", "-e", "1"}, "", "unknown engine \"jit\"\n" + usage, exitUsage},
+		{[]string{"-e", "1;;"}, "1\n", "-e:1:3: warning: Empty statement.\n", exitOK},
+		{[]string{"-e", "let = 1; let y 2"}, "", "-e:1:5: Expected IDENTIFIER, got = instead.\n-e:1:16: Expected =, got INT instead.\n", exitParseError},
+		{[]string{"frobnicate"}, "", "unknown command \"frobnicate\"\n" + usage, exitUsage},
+		{[]string{"run"}, "", usage, exitUsage},
+	}
+
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+		status := run(tt.arguments, strings.NewReader(""), &out, &errOut)
+
+		if status != tt.expectedStatus {
+			t.Errorf("%v: wrong exit status. expected=%d, got=%d", tt.arguments, tt.expectedStatus, status)
+		}
+
+		if out.String() != tt.expectedOut {
+			t.Errorf("%v: wrong output. expected=%q, got=%q", tt.arguments, tt.expectedOut, out.String())
+		}
+
+		if !strings.HasPrefix(errOut.String(), tt.expectedErrOut) {
+			t.Errorf("%v: wrong error output. expected=%q, got=%q", tt.arguments, tt.expectedErrOut, errOut.String())
+		}
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.mk")
+	source := "let greeting = \"Hello \" <> args[0];\nputs(greeting);\nlet broken = greeting + 1;\n"
+	if err := os.WriteFile(script, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+	status := run([]string{"run", script, "World"}, strings.NewReader(""), &out, &errOut)
+
+	if status != exitRuntimeError {
+		t.Errorf("wrong exit status. expected=%d, got=%d", exitRuntimeError, status)
+	}
+
+	expected := "Error: " + script + ":3:23: Type mismatch: STRING + INTEGER\n"
+	if errOut.String() != expected {
+		t.Errorf("wrong error output. expected=%q, got=%q", expected, errOut.String())
+	}
+	if out.String() != "Hello World\n" {
+		t.Errorf("wrong output. expected=%q, got=%q", "Hello World\n", out.String())
+	}
+}
+
+func TestRunPrintsToWriters(t *testing.T) {
+	dir := t.TempDir()
+	lib := `export let shout = fn(s) { puts(s <> "!") };`
+	if err := os.WriteFile(filepath.Join(dir, "loud.mk"), []byte(lib), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		arguments []string
+		out       string
+		errOut    string
+	}{
+		{[]string{"-e", `puts("out"); eputs("err")`}, "out\n", "err\n"},
+		{[]string{"-engine", "vm", "-e", `puts("out"); eputs("err")`}, "out\n", "err\n"},
+		{[]string{"-path", dir, "-e", `import { shout } from "loud"; shout("hey")`}, "hey!\n", ""},
+	}
+
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+		if status := run(tt.arguments, strings.NewReader(""), &out, &errOut); status != exitOK {
+			t.Errorf("%q: wrong exit status %d (%s)", tt.arguments, status, errOut.String())
+			continue
+		}
+		if out.String() != tt.out || errOut.String() != tt.errOut {
+			t.Errorf("%q: wrong output. out=%q, errOut=%q", tt.arguments, out.String(), errOut.String())
+		}
+	}
+}
+
+func TestRunStdin(t *testing.T) {
+	var out, errOut bytes.Buffer
+	status := run([]string{"run", "-"}, strings.NewReader("let x = 5;\nx * 2;"), &out, &errOut)
+
+	if status != exitOK {
+		t.Errorf("wrong exit status. expected=%d, got=%d (%s)", exitOK, status, errOut.String())
+	}
+}
@@ -1,23 +1,56 @@
 package object
 
+import "sort"
+
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	outer   *Environment
+	meter   *Meter
+	modules *Modules
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	return &Environment{
+		store:   map[string]Object{},
+		outer:   outer,
+		meter:   outer.meter,
+		modules: outer.modules,
+	}
 }
 
 func NewEnvironment() *Environment {
 	return &Environment{
-		store: map[string]Object{},
-		outer: nil,
+		store:   map[string]Object{},
+		outer:   nil,
+		meter:   NewMeter(Limits{}),
+		modules: NewModules(),
 	}
 }
 
+// NewModuleEnvironment returns an empty top-level environment for a module
+// imported by the program that importer belongs to. The module runs under
+// the program's limits and shares its module cache.
+func NewModuleEnvironment(importer *Environment) *Environment {
+	return &Environment{
+		store:   map[string]Object{},
+		outer:   importer.modules.Prelude,
+		meter:   importer.meter,
+		modules: importer.modules,
+	}
+}
+
+// Modules returns the cache of modules imported by the program this
+// environment belongs to.
+func (e *Environment) Modules() *Modules {
+	return e.modules
+}
+
+// Meter returns the meter that limits the program this environment
+// belongs to.
+func (e *Environment) Meter() *Meter {
+	return e.meter
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
@@ -30,3 +63,26 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Assign updates an existing binding in the nearest scope that defines name.
+// It reports false, leaving every scope untouched, if name is not defined.
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+// Names returns the names bound in this scope, not its outer scopes, in
+// sorted order.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
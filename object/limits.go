@@ -0,0 +1,197 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxCallDepth bounds recursion when no limit is configured, so that
+// a runaway recursive script fails with an error instead of exhausting the
+// Go stack.
+const DefaultMaxCallDepth = 10000
+
+// DefaultMaxTailCalls bounds tail recursion, which takes no stack space,
+// when no limit is configured, so that a runaway tail-recursive script
+// fails with an error instead of running forever.
+const DefaultMaxTailCalls = 1000000
+
+// contextCheckInterval is how many steps pass between checks of the context,
+// which are too expensive to make on every step.
+const contextCheckInterval = 256
+
+// ErrorKind tells apart errors that need different handling by hosts and
+// scripts. Ordinary runtime errors have no kind.
+type ErrorKind string
+
+const (
+	StepLimitExceeded       ErrorKind = "StepLimitExceeded"
+	CallDepthExceeded       ErrorKind = "CallDepthExceeded"
+	AllocationLimitExceeded ErrorKind = "AllocationLimitExceeded"
+	Canceled                ErrorKind = "Canceled"
+	DeadlineExceeded        ErrorKind = "DeadlineExceeded"
+)
+
+// Catchable reports whether try may catch an error of this kind. Errors
+// with a kind mean a limit was hit or the run was ended, so they stop the
+// script; catching them would let it carry on past its budget.
+func (kind ErrorKind) Catchable() bool {
+	return kind == ""
+}
+
+// Limits bounds the resources a program may use. A zero MaxSteps or
+// MaxAllocations means no limit; a zero MaxCallDepth or MaxTailCalls means
+// DefaultMaxCallDepth or DefaultMaxTailCalls.
+type Limits struct {
+	// MaxSteps is the number of nodes the evaluator may evaluate.
+	MaxSteps int64
+	// MaxCallDepth is how deeply function calls may nest.
+	MaxCallDepth int
+	// MaxTailCalls is how many tail calls may follow each other in place
+	// of one call.
+	MaxTailCalls int
+	// MaxAllocations is the number of objects the program may create.
+	// Arrays and hashes count one object per element, strings one per
+	// eight bytes and integers one per 64 bits.
+	MaxAllocations int64
+}
+
+// Meter enforces Limits and a context's cancellation while a program runs.
+// Every environment of a program shares its root environment's meter. Once
+// a limit is hit or the context ends, the meter keeps failing with the same
+// error until it is Reset, so a program cannot carry on past its budget.
+type Meter struct {
+	limits      Limits
+	ctx         context.Context
+	steps       int64
+	depth       int
+	allocations int64
+	err         *Error
+}
+
+func NewMeter(limits Limits) *Meter {
+	meter := &Meter{}
+	meter.Reset(context.Background(), limits)
+	return meter
+}
+
+// Reset starts a fresh budget under limits that also ends when ctx does.
+func (m *Meter) Reset(ctx context.Context, limits Limits) {
+	if limits.MaxCallDepth == 0 {
+		limits.MaxCallDepth = DefaultMaxCallDepth
+	}
+	if limits.MaxTailCalls == 0 {
+		limits.MaxTailCalls = DefaultMaxTailCalls
+	}
+	*m = Meter{limits: limits, ctx: ctx}
+}
+
+// Step accounts for one evaluation step.
+func (m *Meter) Step() *Error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.steps++
+	if m.limits.MaxSteps > 0 && m.steps > m.limits.MaxSteps {
+		return m.fail(&Error{Kind: StepLimitExceeded, Message: fmt.Sprintf("step limit exceeded (%d steps)", m.limits.MaxSteps)})
+	}
+
+	if m.steps%contextCheckInterval == 0 {
+		return m.checkContext()
+	}
+	return nil
+}
+
+func (m *Meter) checkContext() *Error {
+	switch err := m.ctx.Err(); {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return m.fail(&Error{Kind: DeadlineExceeded, Message: "execution deadline exceeded"})
+	default:
+		return m.fail(&Error{Kind: Canceled, Message: "execution canceled"})
+	}
+}
+
+// fail remembers err as the error every later check returns.
+func (m *Meter) fail(err *Error) *Error {
+	m.err = err
+	return err
+}
+
+// Enter accounts for a function call. Every successful Enter must be
+// followed by a Leave.
+func (m *Meter) Enter() *Error {
+	if m.err != nil {
+		return m.err
+	}
+	if m.depth >= m.limits.MaxCallDepth {
+		return m.fail(&Error{Kind: CallDepthExceeded, Message: fmt.Sprintf("call depth limit exceeded (%d calls)", m.limits.MaxCallDepth)})
+	}
+	m.depth++
+	return nil
+}
+
+func (m *Meter) Leave() {
+	m.depth--
+}
+
+// TailCall accounts for the count-th tail call made in place of one call.
+func (m *Meter) TailCall(count int) *Error {
+	if m.err != nil {
+		return m.err
+	}
+	if count > m.limits.MaxTailCalls {
+		return m.fail(&Error{Kind: CallDepthExceeded, Message: fmt.Sprintf("tail call limit exceeded (%d calls)", m.limits.MaxTailCalls)})
+	}
+	return nil
+}
+
+// Allocate accounts for count newly created objects.
+func (m *Meter) Allocate(count int64) *Error {
+	if m.err != nil {
+		return m.err
+	}
+	m.allocations += count
+	if m.limits.MaxAllocations > 0 && m.allocations > m.limits.MaxAllocations {
+		return m.allocationLimitExceeded()
+	}
+	return nil
+}
+
+// Check reports whether count more objects would exceed MaxAllocations,
+// without accounting for them, so that a large value can be refused before
+// it is created. A nil meter allows anything.
+func (m *Meter) Check(count int64) *Error {
+	if m == nil {
+		return nil
+	}
+	if m.err != nil {
+		return m.err
+	}
+	if m.limits.MaxAllocations > 0 && count > m.limits.MaxAllocations-m.allocations {
+		return m.allocationLimitExceeded()
+	}
+	return nil
+}
+
+func (m *Meter) allocationLimitExceeded() *Error {
+	return m.fail(&Error{Kind: AllocationLimitExceeded, Message: fmt.Sprintf("allocation limit exceeded (%d objects)", m.limits.MaxAllocations)})
+}
+
+// SizeOf is the number of objects obj counts as against MaxAllocations.
+func SizeOf(obj Object) int64 {
+	switch obj := obj.(type) {
+	case *Array:
+		return 1 + int64(len(obj.Elements))
+	case *Hash:
+		return 1 + int64(obj.Len())
+	case *String:
+		return 1 + int64(len(obj.Value))/8
+	case *BigInt:
+		return 1 + int64(obj.Value.BitLen())/64
+	default:
+		return 1
+	}
+}
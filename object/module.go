@@ -0,0 +1,66 @@
+package object
+
+import "strings"
+
+// Module is an imported file. Only the names it exports are visible to the
+// importer; they are looked up in the module's environment on every access,
+// so changes the module makes to them are seen.
+type Module struct {
+	Path    string
+	Env     *Environment
+	Exports map[string]bool
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "<module " + m.Path + ">" }
+
+// Get returns the exported binding name.
+func (m *Module) Get(name string) (Object, bool) {
+	if !m.Exports[name] {
+		return nil, false
+	}
+	return m.Env.Get(name)
+}
+
+// Modules caches the modules a program has imported, by absolute path, and
+// tracks the imports in progress to detect cycles. SearchPath lists the
+// directories searched for modules that are not found next to the
+// importing file. Prelude, if set, encloses every module's environment, so
+// modules see the same host-defined builtins as the program.
+type Modules struct {
+	SearchPath []string
+	Prelude    *Environment
+	loaded     map[string]*Module
+	loading    []string
+}
+
+func NewModules() *Modules {
+	return &Modules{loaded: map[string]*Module{}}
+}
+
+func (m *Modules) Lookup(path string) (*Module, bool) {
+	module, ok := m.loaded[path]
+	return module, ok
+}
+
+// Begin marks path as being imported. If path is already being imported
+// the imports form a cycle, which Begin returns, ending with path, instead.
+func (m *Modules) Begin(path string) (cycle string, ok bool) {
+	for i, loading := range m.loading {
+		if loading == path {
+			return strings.Join(append(m.loading[i:], path), " -> "), false
+		}
+	}
+	m.loading = append(m.loading, path)
+	return "", true
+}
+
+// End finishes the import begun last, caching its module unless the import
+// failed.
+func (m *Modules) End(module *Module) {
+	path := m.loading[len(m.loading)-1]
+	m.loading = m.loading[:len(m.loading)-1]
+	if module != nil {
+		m.loaded[path] = module
+	}
+}
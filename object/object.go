@@ -5,6 +5,11 @@ import (
 	"fmt"
 	"hash/fnv"
 	"interpreter/ast"
+	"interpreter/code"
+	"interpreter/token"
+	"math"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -18,14 +23,21 @@ type Object interface {
 const (
 	NULL_OBJ         = "NULL"
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	STRING_OBJ       = "STRING"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	RETURN_VALUE_OBJ = "RETURN VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
+	ERROR_VALUE_OBJ  = "ERROR_VALUE"
+	MODULE_OBJ       = "MODULE"
 	FUNCTION_OBJ     = "FUNCTION"
 	BUILTIN_OBJ      = "BUILTIN"
-	ARRAY_OBJ        = "ARRAY"
-	HASH_OBJ         = "HASH"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	ARRAY_OBJ             = "ARRAY"
+	HASH_OBJ              = "HASH"
 )
 
 // null
@@ -42,6 +54,34 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+// BigInt is an integer that does not fit in 64 bits. Arithmetic on
+// integers switches to it on overflow and back to Integer when the result
+// fits again, so a BigInt is never equal to an Integer. It reports the same
+// type as Integer since scripts cannot tell the two apart. Value must not
+// be modified.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (i *BigInt) Type() ObjectType { return INTEGER_OBJ }
+func (i *BigInt) Inspect() string  { return i.Value.String() }
+
+// float
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect always shows a float as one, so 2.0 prints as "2.0" rather than "2".
+func (f *Float) Inspect() string {
+	formatted := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if math.IsInf(f.Value, 0) || math.IsNaN(f.Value) || strings.ContainsAny(formatted, ".e") {
+		return formatted
+	}
+	return formatted + ".0"
+}
+
 // boolean
 type Boolean struct {
 	Value bool
@@ -66,33 +106,138 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// break and continue are signals carried out of a loop body the same way a
+// return value is carried out of a function body
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 // error
 type Error struct {
-	Message string
+	Message  string
+	Position token.Position
+	Kind     ErrorKind
+	// Data is the value given to throw or to the error builtin, if any.
+	Data Object
+	// Stack holds the calls that were active when the error happened,
+	// innermost first.
+	Stack []StackFrame
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "Error: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Position.IsValid() {
+		return "Error: " + e.Position.String() + ": " + e.Message
+	}
+	return "Error: " + e.Message
+}
+
+// Traceback is Inspect followed by one line per active call. Runs of
+// identical frames, as left by deep recursion, are shown once with a count,
+// and so are the tail calls left out of the stack.
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+	out.WriteString(e.Inspect())
+
+	for i := 0; i < len(e.Stack); {
+		frame := e.Stack[i]
+		out.WriteString("\n  at " + frame.Name() + " (called at " + frame.Position.String() + ")")
+
+		repeated := 0
+		for i++; i < len(e.Stack) && e.Stack[i-1].Elided == 0 && e.Stack[i].sameCall(frame); i++ {
+			repeated++
+		}
+		if repeated > 0 {
+			out.WriteString(fmt.Sprintf("\n  ... repeated %d more times", repeated))
+		}
+		if elided := e.Stack[i-1].Elided; elided > 0 {
+			out.WriteString(fmt.Sprintf("\n  ... %d earlier tail calls not shown", elided))
+		}
+	}
+
+	return out.String()
+}
+
+// ErrorValue holds an error as an ordinary value, so that it can be bound
+// to a variable without aborting the program: the variable of a catch
+// clause, or the result of the error builtin. Throwing it raises Error.
+type ErrorValue struct {
+	Error *Error
+}
+
+func (ev *ErrorValue) Type() ObjectType { return ERROR_VALUE_OBJ }
+func (ev *ErrorValue) Inspect() string  { return ev.Error.Inspect() }
+
+// StackFrame is a function call: the name of the function that was called,
+// which is empty for an anonymous function, and the position of the call.
+type StackFrame struct {
+	Function string
+	Position token.Position
+	// Elided counts the earlier tail calls left out of the stack after
+	// this frame, of which only the innermost are kept.
+	Elided int
+}
+
+func (f StackFrame) sameCall(other StackFrame) bool {
+	return f.Function == other.Function && f.Position == other.Position
+}
+
+func (f StackFrame) Name() string {
+	if f.Function == "" {
+		return "<anonymous>"
+	}
+	return f.Function
+}
 
 // function
 type Function struct {
+	// Name is the name the function was first bound to with let, if any.
+	Name       string
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // as in ast.FunctionLiteral
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
 
-func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
-func (f *Function) Inspect() string {
-	var buffer bytes.Buffer
+// Default returns the default value of the ith parameter, or nil.
+func (f *Function) Default(i int) ast.Expression {
+	if i < len(f.Defaults) {
+		return f.Defaults[i]
+	}
+	return nil
+}
 
+// ParameterList returns the parameters as written in the source, with
+// their default values and the rest parameter.
+func (f *Function) ParameterList() []string {
 	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		if value := f.Default(i); value != nil {
+			params = append(params, p.String()+" = "+value.String())
+		} else {
+			params = append(params, p.String())
+		}
 	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
+	return params
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	var buffer bytes.Buffer
 
 	buffer.WriteString("fn")
 	buffer.WriteString("(")
-	buffer.WriteString(strings.Join(params, ", "))
+	buffer.WriteString(strings.Join(f.ParameterList(), ", "))
 	buffer.WriteString(") {\n")
 	buffer.WriteString(f.Body.String())
 	buffer.WriteString("\n}")
@@ -103,34 +248,158 @@ func (f *Function) Inspect() string {
 // builtin function
 type BuiltInFunction func(args ...Object) Object
 
+// Caller applies a function value to arguments. The engine running the
+// program passes one to higher-order builtins so that they can call back
+// into script functions.
+type Caller func(fn Object, args ...Object) Object
+
+// HigherOrderFunction is a builtin that takes functions as arguments.
+type HigherOrderFunction func(call Caller, args ...Object) Object
+
+// SizedFunction is a builtin whose result can be far larger than its
+// arguments. It checks the size of the result against meter, which is nil
+// when nothing limits the program, before creating it.
+type SizedFunction func(meter *Meter, args ...Object) Object
+
 type BuiltIn struct {
 	Fn BuiltInFunction
+	// HigherOrder, if set, is called instead of Fn.
+	HigherOrder HigherOrderFunction
+	// Sized, if set, is called instead of Fn.
+	Sized SizedFunction
+}
+
+// Call runs the builtin, giving it call to apply function arguments and
+// meter to account for what it creates.
+func (bi *BuiltIn) Call(call Caller, meter *Meter, args ...Object) Object {
+	switch {
+	case bi.HigherOrder != nil:
+		return bi.HigherOrder(call, args...)
+	case bi.Sized != nil:
+		return bi.Sized(meter, args...)
+	default:
+		return bi.Fn(args...)
+	}
 }
 
 func (bi *BuiltIn) Type() ObjectType { return BUILTIN_OBJ }
 func (bi *BuiltIn) Inspect() string  { return "builtin function" }
 
+// compiled function
+type CompiledFunction struct {
+	Name          string
+	Instructions  code.Instructions
+	SourceMap     code.SourceMap
+	NumLocals     int
+	NumParameters int
+	Parameters    []string
+	Captures      []Capture
+	LocalNames    []string
+}
+
+// Capture describes where a closure finds one of its free variables when it
+// is created: a local slot of the enclosing frame, or one of the enclosing
+// closure's own free variables.
+type Capture struct {
+	Name  string
+	Local bool
+	Index int
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// closure
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Upvalue
+}
+
+// A closure is the VM's representation of a function value, so it reports the
+// same type as the evaluator's functions.
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string {
+	return "fn(" + strings.Join(c.Fn.Parameters, ", ") + ") { <compiled> }"
+}
+
+// Upvalue is a variable captured by a closure. While the frame that owns the
+// variable is running, Location points at its stack slot so every closure
+// sees assignments made through the others. Close moves the value off the
+// stack once that slot is about to be reused.
+type Upvalue struct {
+	Location *Object
+	closed   Object
+}
+
+func (u *Upvalue) Close() {
+	u.closed = *u.Location
+	u.Location = &u.closed
+}
+
 // array literal
 type Array struct {
 	Elements []Object
 }
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJ }
-func (a *Array) Inspect() string {
+func (a *Array) Inspect() string  { return inspect(a, nil) }
+
+// inspect writes arrays and hashes element by element. open holds the ones
+// being written further up, so that a value that contains itself, which
+// index assignment can make, is written as [...] or {...} where it recurs
+// instead of endlessly.
+func inspect(obj Object, open map[Object]bool) string {
 	var buffer bytes.Buffer
 
-	elements := []string{}
-	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
+	switch obj := obj.(type) {
+	case *Array:
+		if open[obj] {
+			return "[...]"
+		}
+		open = markOpen(open, obj)
+		defer delete(open, obj)
+
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, open))
+		}
+
+		buffer.WriteString("[")
+		buffer.WriteString(strings.Join(elements, ", "))
+		buffer.WriteString("]")
+	case *Hash:
+		if open[obj] {
+			return "{...}"
+		}
+		open = markOpen(open, obj)
+		defer delete(open, obj)
+
+		pairs := []string{}
+		for _, pair := range obj.Ordered() {
+			pairs = append(pairs, fmt.Sprintf("%s: %s",
+				inspect(pair.Key, open), inspect(pair.Value, open)))
+		}
+
+		buffer.WriteString("{")
+		buffer.WriteString(strings.Join(pairs, ", "))
+		buffer.WriteString("}")
+	default:
+		return obj.Inspect()
 	}
 
-	buffer.WriteString("[")
-	buffer.WriteString(strings.Join(elements, ", "))
-	buffer.WriteString("]")
-
 	return buffer.String()
 }
 
+func markOpen(open map[Object]bool, obj Object) map[Object]bool {
+	if open == nil {
+		open = map[Object]bool{}
+	}
+	open[obj] = true
+	return open
+}
+
 // hash key
 type HashKey struct {
 	Type  ObjectType
@@ -153,15 +422,28 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (i *BigInt) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: hashString(i.Value.String())}
+}
+
+func (f *Float) HashKey() HashKey {
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func (s *String) HashKey() HashKey {
-	h := fnv.New64a()
-	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: hashString(s.Value)}
+}
 
-	return HashKey{Type: s.Type(), Value: h.Sum64()}
+// hashString is a variable so that tests can force collisions.
+var hashString = func(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
 }
 
 // hash
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
@@ -170,23 +452,109 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash maps keys to values and remembers the order in which keys were
+// first added, which is the order it is printed and iterated in. Pairs are
+// stored in buckets by HashKey and found by comparing the keys themselves,
+// so keys whose HashKeys collide are still kept apart.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	buckets map[HashKey][]HashPair
+	order   []Hashable
 }
 
-func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
-	var buffer bytes.Buffer
+func NewHash() *Hash {
+	return &Hash{buckets: map[HashKey][]HashPair{}}
+}
+
+// Set adds or replaces the value for key. A replaced key keeps its place.
+func (h *Hash) Set(key Hashable, value Object) {
+	if h.buckets == nil {
+		h.buckets = map[HashKey][]HashPair{}
+	}
+	hashKey := key.HashKey()
+	bucket := h.buckets[hashKey]
+	if i := findKey(bucket, key); i >= 0 {
+		bucket[i].Value = value
+		return
+	}
+	h.buckets[hashKey] = append(bucket, HashPair{Key: key, Value: value})
+	h.order = append(h.order, key)
+}
 
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	bucket := h.buckets[key.HashKey()]
+	if i := findKey(bucket, key); i >= 0 {
+		return bucket[i].Value, true
 	}
+	return nil, false
+}
 
-	buffer.WriteString("{")
-	buffer.WriteString(strings.Join(pairs, ", "))
-	buffer.WriteString("}")
+// Delete removes key and reports whether it was present.
+func (h *Hash) Delete(key Hashable) bool {
+	hashKey := key.HashKey()
+	bucket := h.buckets[hashKey]
+	i := findKey(bucket, key)
+	if i < 0 {
+		return false
+	}
+	if len(bucket) == 1 {
+		delete(h.buckets, hashKey)
+	} else {
+		h.buckets[hashKey] = append(bucket[:i:i], bucket[i+1:]...)
+	}
 
-	return buffer.String()
+	for i, ordered := range h.order {
+		if keysEqual(ordered, key) {
+			h.order = append(h.order[:i:i], h.order[i+1:]...)
+			break
+		}
+	}
+	return true
 }
+
+func (h *Hash) Len() int { return len(h.order) }
+
+// Ordered returns the pairs in insertion order.
+func (h *Hash) Ordered() []HashPair {
+	pairs := make([]HashPair, len(h.order))
+	for i, key := range h.order {
+		value, _ := h.Get(key)
+		pairs[i] = HashPair{Key: key, Value: value}
+	}
+	return pairs
+}
+
+func findKey(bucket []HashPair, key Hashable) int {
+	for i, pair := range bucket {
+		if keysEqual(pair.Key.(Hashable), key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// keysEqual reports whether two hash keys are the same key. Keys of
+// different types never are, even if they compare equal with ==.
+func keysEqual(a, b Hashable) bool {
+	switch a := a.(type) {
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *BigInt:
+		b, ok := b.(*BigInt)
+		return ok && a.Value.Cmp(b.Value) == 0
+	case *Float:
+		b, ok := b.(*Float)
+		return ok && math.Float64bits(a.Value) == math.Float64bits(b.Value)
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	default:
+		return a == b
+	}
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string  { return inspect(h, nil) }
@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -20,3 +23,156 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestHashOrder(t *testing.T) {
+	hash := NewHash()
+	for _, key := range []string{"c", "a", "b"} {
+		hash.Set(&String{Value: key}, &Integer{Value: int64(len(key))})
+	}
+	hash.Set(&String{Value: "a"}, &Integer{Value: 10})
+	hash.Delete(&String{Value: "c"})
+	hash.Set(&String{Value: "c"}, &Integer{Value: 3})
+
+	if hash.Inspect() != "{a: 10, b: 1, c: 3}" {
+		t.Errorf("wrong order. got=%s", hash.Inspect())
+	}
+
+	if hash.Delete(&String{Value: "missing"}) {
+		t.Errorf("Delete reported a missing key as present")
+	}
+
+	if value, ok := hash.Get(&String{Value: "b"}); !ok || value.Inspect() != "1" {
+		t.Errorf("Get returned %v, %t", value, ok)
+	}
+}
+
+// collidingKey is a hash key whose HashKey is the same for every value.
+type collidingKey struct{ name string }
+
+func (k *collidingKey) Type() ObjectType { return "COLLIDING" }
+func (k *collidingKey) Inspect() string  { return k.name }
+func (k *collidingKey) HashKey() HashKey { return HashKey{Type: k.Type(), Value: 42} }
+
+func TestHashCollisions(t *testing.T) {
+	defer func(original func(string) uint64) { hashString = original }(hashString)
+	hashString = func(string) uint64 { return 7 }
+
+	a, b, c := &String{Value: "a"}, &String{Value: "b"}, &String{Value: "c"}
+	if a.HashKey() != b.HashKey() {
+		t.Fatalf("collision was not forced")
+	}
+
+	hash := NewHash()
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+	hash.Set(c, &Integer{Value: 3})
+	hash.Set(&String{Value: "b"}, &Integer{Value: 20})
+
+	if hash.Len() != 3 || hash.Inspect() != "{a: 1, b: 20, c: 3}" {
+		t.Fatalf("colliding keys overwrote each other. got=%s", hash.Inspect())
+	}
+
+	for key, expected := range map[string]string{"a": "1", "b": "20", "c": "3"} {
+		value, ok := hash.Get(&String{Value: key})
+		if !ok || value.Inspect() != expected {
+			t.Errorf("Get(%q) returned %v, %t", key, value, ok)
+		}
+	}
+
+	if _, ok := hash.Get(&String{Value: "d"}); ok {
+		t.Errorf("Get found a missing key with a colliding HashKey")
+	}
+
+	if !hash.Delete(b) || hash.Inspect() != "{a: 1, c: 3}" {
+		t.Errorf("Delete removed the wrong pair. got=%s", hash.Inspect())
+	}
+	if value, ok := hash.Get(c); !ok || value.Inspect() != "3" {
+		t.Errorf("Delete lost a colliding key. got=%v, %t", value, ok)
+	}
+	if hash.Delete(&String{Value: "d"}) {
+		t.Errorf("Delete removed a missing key with a colliding HashKey")
+	}
+}
+
+func TestHashCollidingCustomKeys(t *testing.T) {
+	first, second := &collidingKey{"first"}, &collidingKey{"second"}
+
+	hash := NewHash()
+	hash.Set(first, &Integer{Value: 1})
+	hash.Set(second, &Integer{Value: 2})
+
+	if value, ok := hash.Get(first); !ok || value.Inspect() != "1" {
+		t.Errorf("Get(first) returned %v, %t", value, ok)
+	}
+	if value, ok := hash.Get(second); !ok || value.Inspect() != "2" {
+		t.Errorf("Get(second) returned %v, %t", value, ok)
+	}
+	if _, ok := hash.Get(&collidingKey{"first"}); ok {
+		t.Errorf("keys without value equality matched by HashKey alone")
+	}
+}
+
+func TestHashKeyTypesAreDistinct(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&Integer{Value: 1}, &String{Value: "integer"})
+	hash.Set(&Float{Value: 1}, &String{Value: "float"})
+	hash.Set(&Boolean{Value: true}, &String{Value: "boolean"})
+	hash.Set(&String{Value: "1"}, &String{Value: "string"})
+
+	if hash.Len() != 4 {
+		t.Errorf("keys of different types were merged. got=%s", hash.Inspect())
+	}
+}
+
+func TestInspectCyclicValues(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+
+	hash := NewHash()
+	hash.Set(&String{Value: "self"}, hash)
+	hash.Set(&String{Value: "array"}, array)
+
+	shared := &Array{Elements: []Object{&Integer{Value: 2}}}
+	twice := &Array{Elements: []Object{shared, shared}}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{array, "[1, [...]]"},
+		{hash, "{self: {...}, array: [1, [...]]}"},
+		{twice, "[[2], [2]]"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("wrong Inspect. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestMeterRemembersFailure(t *testing.T) {
+	meter := NewMeter(Limits{MaxSteps: 1})
+	if err := meter.Step(); err != nil {
+		t.Fatalf("first step failed: %s", err.Message)
+	}
+
+	first := meter.Step()
+	if first == nil || first.Kind != StepLimitExceeded {
+		t.Fatalf("expected the step limit. got=%v", first)
+	}
+	if err := meter.Enter(); err != first {
+		t.Errorf("Enter should fail with the step limit. got=%v", err)
+	}
+	if err := meter.Allocate(1); err != first {
+		t.Errorf("Allocate should fail with the step limit. got=%v", err)
+	}
+	if err := meter.Check(1); err != first {
+		t.Errorf("Check should fail with the step limit. got=%v", err)
+	}
+
+	meter.Reset(context.Background(), Limits{})
+	if err := meter.Step(); err != nil {
+		t.Errorf("Reset should clear the failure. got=%s", err.Message)
+	}
+}
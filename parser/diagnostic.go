@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"interpreter/token"
+	"strings"
+)
+
+// Severity tells whether a diagnostic keeps a program from running.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (severity Severity) String() string {
+	if severity == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic is a problem found in the source. Expected lists the tokens
+// that would have been accepted, when the parser knows them, and Found is
+// the token it saw instead.
+type Diagnostic struct {
+	Position token.Position
+	Severity Severity
+	Message  string
+	Expected []token.TokenType
+	Found    token.Token
+}
+
+// String formats an error as "position: message"; warnings say so.
+func (diagnostic Diagnostic) String() string {
+	if diagnostic.Severity == Warning {
+		return diagnostic.Position.String() + ": warning: " + diagnostic.Message
+	}
+	return diagnostic.Position.String() + ": " + diagnostic.Message
+}
+
+func expectedList(expected []token.TokenType) string {
+	names := make([]string, len(expected))
+	for i, tokenType := range expected {
+		names[i] = string(tokenType)
+	}
+	return strings.Join(names, " or ")
+}
@@ -5,6 +5,7 @@ import (
 	"interpreter/ast"
 	"interpreter/lexer"
 	"interpreter/token"
+	"math/big"
 	"strconv"
 )
 
@@ -16,7 +17,10 @@ type Parser struct {
 	lexer                *lexer.Lexer
 	currentToken         token.Token
 	peekToken            token.Token
-	errors               []string
+	diagnostics          []Diagnostic
+	panicking            bool
+	offending            token.Token // the token that started panic mode
+	blockDepth           int
 	prefixParseFunctions map[token.TokenType]prefixParseFunction
 	infixParseFunctions  map[token.TokenType]infixParseFunction
 }
@@ -24,33 +28,56 @@ type Parser struct {
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // = or +=
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
+	SUM         // + or |
+	PRODUCT     // * or &
 	PREFIX      // -x or !x
+	POWER       // **
 	CALL        // function(x)
 	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.LTGT:     SUM,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.LTGT_ASSIGN:     ASSIGN,
+	token.OR:              OR,
+	token.AND:             AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.LTGT:            SUM,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.PIPE:            SUM,
+	token.CARET:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.DIV:             PRODUCT,
+	token.AMPERSAND:       PRODUCT,
+	token.SHIFT_LEFT:      PRODUCT,
+	token.SHIFT_RIGHT:     PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.DOT:             INDEX,
 }
 
 func New(lex *lexer.Lexer) *Parser {
 	parser := &Parser{
-		lexer:  lex,
-		errors: []string{},
+		lexer:       lex,
+		diagnostics: []Diagnostic{},
 	}
 	parser.nextToken()
 	parser.nextToken()
@@ -58,11 +85,14 @@ func New(lex *lexer.Lexer) *Parser {
 	parser.prefixParseFunctions = make(map[token.TokenType]prefixParseFunction)
 	parser.registerPrefix(token.IDENTIFIER, parser.parseIdentifier)
 	parser.registerPrefix(token.INT, parser.parseIntegerLiteral)
+	parser.registerPrefix(token.FLOAT, parser.parseFloatLiteral)
 	parser.registerPrefix(token.STRING, parser.parseStringLiteral)
 	parser.registerPrefix(token.BANG, parser.parsePrefixExpression)
 	parser.registerPrefix(token.MINUS, parser.parsePrefixExpression)
 	parser.registerPrefix(token.LPAREN, parser.parseGroupedExpression)
 	parser.registerPrefix(token.IF, parser.parseIfExpression)
+	parser.registerPrefix(token.TRY, parser.parseTryExpression)
+	parser.registerPrefix(token.IMPORT, parser.parseImportExpression)
 	parser.registerPrefix(token.FUNCTION, parser.parseFunctionLiteral)
 	parser.registerPrefix(token.TRUE, parser.parseBoolean)
 	parser.registerPrefix(token.FALSE, parser.parseBoolean)
@@ -78,9 +108,28 @@ func New(lex *lexer.Lexer) *Parser {
 	parser.registerInfix(token.NOT_EQ, parser.parseInfixExpression)
 	parser.registerInfix(token.LT, parser.parseInfixExpression)
 	parser.registerInfix(token.GT, parser.parseInfixExpression)
+	parser.registerInfix(token.LT_EQ, parser.parseInfixExpression)
+	parser.registerInfix(token.GT_EQ, parser.parseInfixExpression)
 	parser.registerInfix(token.LTGT, parser.parseInfixExpression)
+	parser.registerInfix(token.PERCENT, parser.parseInfixExpression)
+	parser.registerInfix(token.DIV, parser.parseInfixExpression)
+	parser.registerInfix(token.AND, parser.parseInfixExpression)
+	parser.registerInfix(token.OR, parser.parseInfixExpression)
+	parser.registerInfix(token.AMPERSAND, parser.parseInfixExpression)
+	parser.registerInfix(token.PIPE, parser.parseInfixExpression)
+	parser.registerInfix(token.CARET, parser.parseInfixExpression)
+	parser.registerInfix(token.SHIFT_LEFT, parser.parseInfixExpression)
+	parser.registerInfix(token.SHIFT_RIGHT, parser.parseInfixExpression)
+	parser.registerInfix(token.POWER, parser.parseInfixExpression)
+	parser.registerInfix(token.ASSIGN, parser.parseAssignExpression)
+	parser.registerInfix(token.PLUS_ASSIGN, parser.parseAssignExpression)
+	parser.registerInfix(token.MINUS_ASSIGN, parser.parseAssignExpression)
+	parser.registerInfix(token.ASTERISK_ASSIGN, parser.parseAssignExpression)
+	parser.registerInfix(token.SLASH_ASSIGN, parser.parseAssignExpression)
+	parser.registerInfix(token.LTGT_ASSIGN, parser.parseAssignExpression)
 	parser.registerInfix(token.LPAREN, parser.parseCallExpression)
 	parser.registerInfix(token.LBRACKET, parser.parseIndexExpression)
+	parser.registerInfix(token.DOT, parser.parseMemberExpression)
 
 	return parser
 }
@@ -102,23 +151,106 @@ func (parser *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
-	for parser.currentToken.Type != token.EOF {
+	program.Statements = parser.parseStatements(token.EOF)
+
+	program.Comments = parser.lexer.Comments()
+	for _, err := range parser.lexer.Errors() {
+		parser.diagnostics = append(parser.diagnostics, Diagnostic{
+			Position: err.Position,
+			Severity: Error,
+			Message:  err.Message,
+		})
+	}
+
+	return program
+}
+
+// parseStatements parses statements up to the end token. When a statement
+// fails it is dropped and parsing resumes at the next statement boundary, so
+// that one mistake is reported once and the statements after it are still
+// checked.
+func (parser *Parser) parseStatements(end token.TokenType) []ast.Statement {
+	statements := []ast.Statement{}
+
+	for !parser.currentTokenIs(end) && !parser.currentTokenIs(token.EOF) {
+		start := parser.currentToken
 		statement := parser.parseStatement()
+
+		if parser.panicking {
+			parser.synchronize(start)
+			continue
+		}
+
 		if statement != nil {
-			program.Statements = append(program.Statements, statement)
+			statements = append(statements, statement)
 		}
 		parser.nextToken()
 	}
 
-	return program
+	return statements
+}
+
+// synchronize leaves panic mode and skips to the start of the next
+// statement: just past a semicolon, or at a closing brace, `let` or
+// `return`. The current token is skipped unless it is the one the error was
+// about, since it then belongs to the statement that failed; start, the
+// first token of that statement, is always skipped so parsing progresses.
+// A closing brace outside any block belongs to the failed statement too, so
+// it is skipped rather than reported again.
+func (parser *Parser) synchronize(start token.Token) {
+	parser.panicking = false
+
+	if parser.currentToken == start || parser.currentToken != parser.offending {
+		parser.nextToken()
+	}
+
+	for !parser.currentTokenIs(token.EOF) {
+		switch parser.currentToken.Type {
+		case token.SEMICOLON:
+			parser.nextToken()
+			return
+		case token.RBRACE:
+			if parser.blockDepth > 0 {
+				return
+			}
+		case token.LET, token.RETURN:
+			return
+		}
+		parser.nextToken()
+	}
 }
 
 func (parser *Parser) parseStatement() ast.Statement {
 	switch parser.currentToken.Type {
+	case token.SEMICOLON:
+		parser.report(Diagnostic{
+			Position: parser.currentToken.Position,
+			Severity: Warning,
+			Message:  "Empty statement.",
+			Found:    parser.currentToken,
+		})
+		return nil
 	case token.LET:
 		return parser.parseLetStatement()
 	case token.RETURN:
 		return parser.parseReturnStatement()
+	case token.WHILE:
+		return parser.parseWhileStatement()
+	case token.FOR:
+		return parser.parseForStatement()
+	case token.BREAK:
+		return parser.parseBreakStatement()
+	case token.CONTINUE:
+		return parser.parseContinueStatement()
+	case token.THROW:
+		return parser.parseThrowStatement()
+	case token.EXPORT:
+		return parser.parseExportStatement()
+	case token.IMPORT:
+		if parser.peekTokenIs(token.LBRACE) {
+			return parser.parseImportStatement()
+		}
+		return parser.parseExpressionStatement()
 	default:
 		return parser.parseExpressionStatement()
 	}
@@ -160,6 +292,179 @@ func (parser *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
+func (parser *Parser) parseWhileStatement() ast.Statement {
+	statement := &ast.WhileStatement{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	statement.Condition = parser.parseGroupedExpression()
+
+	if !parser.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Body = parser.parseBlockStatement()
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
+func (parser *Parser) parseForStatement() ast.Statement {
+	statement := &ast.ForStatement{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !parser.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	statement.Value = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+
+	if parser.peekTokenIs(token.COMMA) {
+		parser.nextToken()
+
+		if !parser.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+
+		statement.Key = statement.Value
+		statement.Value = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+	}
+
+	if !parser.expectPeek(token.IN) {
+		return nil
+	}
+
+	parser.nextToken()
+	statement.Iterable = parser.parseExpression(LOWEST)
+
+	if !parser.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !parser.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Body = parser.parseBlockStatement()
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
+func (parser *Parser) parseBreakStatement() ast.Statement {
+	statement := &ast.BreakStatement{Token: parser.currentToken}
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
+func (parser *Parser) parseContinueStatement() ast.Statement {
+	statement := &ast.ContinueStatement{Token: parser.currentToken}
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
+func (parser *Parser) parseThrowStatement() ast.Statement {
+	statement := &ast.ThrowStatement{Token: parser.currentToken}
+
+	parser.nextToken()
+	statement.Value = parser.parseExpression(LOWEST)
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
+func (parser *Parser) parseExportStatement() ast.Statement {
+	statement := &ast.ExportStatement{Token: parser.currentToken}
+
+	if parser.blockDepth > 0 {
+		parser.report(Diagnostic{
+			Position: parser.currentToken.Position,
+			Message:  "Export is only allowed at the top level.",
+			Found:    parser.currentToken,
+		})
+		return nil
+	}
+
+	if !parser.expectPeek(token.LET) {
+		return nil
+	}
+
+	statement.Statement = parser.parseLetStatement()
+	if statement.Statement == nil {
+		return nil
+	}
+
+	return statement
+}
+
+// parseImportStatement parses `import { a, b } from "path"`. The word from
+// is only special here, so it remains usable as a name.
+func (parser *Parser) parseImportStatement() ast.Statement {
+	statement := &ast.ImportStatement{Token: parser.currentToken}
+
+	parser.nextToken()
+
+	for {
+		if !parser.expectPeek(token.IDENTIFIER) {
+			return nil
+		}
+		statement.Names = append(statement.Names, &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal})
+
+		if !parser.peekTokenIs(token.COMMA) {
+			break
+		}
+		parser.nextToken()
+	}
+
+	if !parser.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	if !parser.peekTokenIs(token.IDENTIFIER) || parser.peekToken.Literal != "from" {
+		parser.report(Diagnostic{
+			Position: parser.peekToken.Position,
+			Message:  fmt.Sprintf("Expected from, got %s instead.", parser.peekToken.Literal),
+			Expected: []token.TokenType{token.IDENTIFIER},
+			Found:    parser.peekToken,
+		})
+		return nil
+	}
+	parser.nextToken()
+
+	if !parser.expectPeek(token.STRING) {
+		return nil
+	}
+	statement.Path = &ast.StringLiteral{Token: parser.currentToken, Value: parser.currentToken.Literal}
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return statement
+}
+
 func (parser *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	statement := &ast.ExpressionStatement{Token: parser.currentToken}
 	statement.Expression = parser.parseExpression(LOWEST)
@@ -204,6 +509,9 @@ func (parser *Parser) parsePrefixExpression() ast.Expression {
 	return expression
 }
 
+// parseInfixExpression parses the right operand at the operator's own
+// precedence, making operators left associative, except for ** which is
+// right associative: 2 ** 3 ** 2 is 2 ** (3 ** 2).
 func (parser *Parser) parseInfixExpression(leftOperand ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{
 		Token:       parser.currentToken,
@@ -212,18 +520,131 @@ func (parser *Parser) parseInfixExpression(leftOperand ast.Expression) ast.Expre
 	}
 
 	precedence := parser.currentPrecedence()
+	if parser.currentTokenIs(token.POWER) {
+		precedence--
+	}
 	parser.nextToken()
 	expression.RightOperand = parser.parseExpression(precedence)
 
 	return expression
 }
 
+// parseAssignExpression parses the right-hand side with LOWEST precedence so
+// that assignment is right associative: a = b = 1 is a = (b = 1).
+func (parser *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    parser.currentToken,
+		Target:   target,
+		Operator: parser.currentToken.Literal,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	case nil:
+		return nil
+	default:
+		// A target that already failed to parse may be missing operands,
+		// and the error about it is the one to keep.
+		if parser.panicking {
+			return nil
+		}
+		parser.report(Diagnostic{
+			Position: parser.currentToken.Position,
+			Message:  fmt.Sprintf("Cannot assign to %s.", target.String()),
+			Found:    parser.currentToken,
+		})
+		return nil
+	}
+
+	parser.nextToken()
+	expression.Value = parser.parseExpression(LOWEST)
+
+	return expression
+}
+
 func (parser *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{Token: parser.currentToken, Function: function}
-	expression.Arguments = parser.parseExpressionList(token.RPAREN)
+	expression.Arguments = parser.parseCallArguments()
 	return expression
 }
 
+// parseCallArguments parses arguments like parseExpressionList, and also
+// ...array to spread an array over several arguments and name: value to pass
+// an argument by parameter name. Named arguments come last.
+func (parser *Parser) parseCallArguments() []ast.Expression {
+	arguments := []ast.Expression{}
+
+	if parser.peekTokenIs(token.RPAREN) {
+		parser.nextToken()
+		return arguments
+	}
+
+	names := map[string]bool{}
+	for {
+		parser.nextToken()
+		argument := parser.parseCallArgument()
+		if argument == nil {
+			return nil
+		}
+
+		if named, ok := argument.(*ast.NamedArgument); ok {
+			if names[named.Name.Value] {
+				parser.report(Diagnostic{
+					Position: named.Token.Position,
+					Message:  fmt.Sprintf("Duplicate named argument %s.", named.Name.Value),
+					Found:    named.Token,
+				})
+				return nil
+			}
+			names[named.Name.Value] = true
+		} else if len(names) > 0 {
+			parser.report(Diagnostic{
+				Position: argument.Pos(),
+				Message:  "Positional argument follows named argument.",
+				Found:    parser.currentToken,
+			})
+			return nil
+		}
+		arguments = append(arguments, argument)
+
+		if !parser.peekTokenIs(token.COMMA) {
+			break
+		}
+		parser.nextToken()
+	}
+
+	if !parser.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return arguments
+}
+
+func (parser *Parser) parseCallArgument() ast.Expression {
+	switch {
+	case parser.currentTokenIs(token.ELLIPSIS):
+		spread := &ast.SpreadExpression{Token: parser.currentToken}
+		parser.nextToken()
+		if spread.Value = parser.parseExpression(LOWEST); spread.Value == nil {
+			return nil
+		}
+		return spread
+	case parser.currentTokenIs(token.IDENTIFIER) && parser.peekTokenIs(token.COLON):
+		named := &ast.NamedArgument{
+			Token: parser.currentToken,
+			Name:  &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal},
+		}
+		parser.nextToken()
+		parser.nextToken()
+		if named.Value = parser.parseExpression(LOWEST); named.Value == nil {
+			return nil
+		}
+		return named
+	default:
+		return parser.parseExpression(LOWEST)
+	}
+}
+
 func (parser *Parser) parseGroupedExpression() ast.Expression {
 	parser.nextToken()
 	expression := parser.parseExpression(LOWEST)
@@ -244,8 +665,32 @@ func (parser *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(parser.currentToken.Literal, 0, 64)
 	if err != nil {
-		message := fmt.Sprintf("Could not parse %q as integer", parser.currentToken.Literal)
-		parser.errors = append(parser.errors, message)
+		if n, ok := new(big.Int).SetString(parser.currentToken.Literal, 0); ok {
+			literal.Big = n
+			return literal
+		}
+		parser.report(Diagnostic{
+			Position: parser.currentToken.Position,
+			Message:  fmt.Sprintf("Could not parse %q as integer", parser.currentToken.Literal),
+			Found:    parser.currentToken,
+		})
+		return nil
+	}
+
+	literal.Value = value
+	return literal
+}
+
+func (parser *Parser) parseFloatLiteral() ast.Expression {
+	literal := &ast.FloatLiteral{Token: parser.currentToken}
+
+	value, err := strconv.ParseFloat(parser.currentToken.Literal, 64)
+	if err != nil {
+		parser.report(Diagnostic{
+			Position: parser.currentToken.Position,
+			Message:  fmt.Sprintf("Could not parse %q as float", parser.currentToken.Literal),
+			Found:    parser.currentToken,
+		})
 		return nil
 	}
 
@@ -288,21 +733,58 @@ func (parser *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
-func (parser *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{Token: parser.currentToken}
-	block.Statements = []ast.Statement{}
+func (parser *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: parser.currentToken}
 
-	parser.nextToken()
+	if !parser.expectPeek(token.LBRACE) {
+		return nil
+	}
 
-	for !parser.currentTokenIs(token.RBRACE) && !parser.currentTokenIs(token.EOF) {
-		statement := parser.parseStatement()
-		if statement != nil {
-			block.Statements = append(block.Statements, statement)
+	expression.Block = parser.parseBlockStatement()
+
+	if parser.peekTokenIs(token.CATCH) {
+		parser.nextToken()
+
+		if !parser.expectPeek(token.LPAREN) || !parser.expectPeek(token.IDENTIFIER) {
+			return nil
 		}
 
+		expression.Parameter = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+
+		if !parser.expectPeek(token.RPAREN) || !parser.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Catch = parser.parseBlockStatement()
+	}
+
+	if parser.peekTokenIs(token.FINALLY) {
 		parser.nextToken()
+
+		if !parser.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Finally = parser.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		parser.peekError(token.CATCH, token.FINALLY)
+		return nil
 	}
 
+	return expression
+}
+
+func (parser *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: parser.currentToken}
+
+	parser.blockDepth++
+	defer func() { parser.blockDepth-- }()
+
+	parser.nextToken()
+	block.Statements = parser.parseStatements(token.RBRACE)
+
 	return block
 }
 
@@ -317,17 +799,53 @@ func (parser *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	expression.Parameters = parser.parseFunctionParameters()
+	if !parser.parseFunctionParameters(expression) {
+		return nil
+	}
 
 	if !parser.expectPeek(token.LBRACE) {
 		return nil
 	}
 
 	expression.Body = parser.parseBlockStatement()
+	markTailCalls(expression.Body, true)
 
 	return expression
 }
 
+// markTailCalls marks the calls in block whose value the function returns as
+// is: the operand of a return statement and, when the block ends the
+// function, its last expression. It looks through the branches of if and
+// the right operand of && and ||, but not into loops or try, whose calls
+// have work left to do afterwards.
+func markTailCalls(block *ast.BlockStatement, tail bool) {
+	if block == nil {
+		return
+	}
+	for i, statement := range block.Statements {
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			markTailCall(statement.Value, true)
+		case *ast.ExpressionStatement:
+			markTailCall(statement.Expression, tail && i == len(block.Statements)-1)
+		}
+	}
+}
+
+func markTailCall(expression ast.Expression, tail bool) {
+	switch expression := expression.(type) {
+	case *ast.CallExpression:
+		expression.Tail = tail
+	case *ast.IfExpression:
+		markTailCalls(expression.Consequence, tail)
+		markTailCalls(expression.Alternative, tail)
+	case *ast.InfixExpression:
+		if expression.Operator == "&&" || expression.Operator == "||" {
+			markTailCall(expression.RightOperand, tail)
+		}
+	}
+}
+
 func (parser *Parser) parseArrayLiteral() ast.Expression {
 	return &ast.ArrayLiteral{
 		Token:    parser.currentToken,
@@ -351,6 +869,7 @@ func (parser *Parser) parseHashLiteral() ast.Expression {
 		value := parser.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !parser.peekTokenIs(token.RBRACE) && !parser.expectPeek(token.COMMA) {
 			return nil
@@ -388,6 +907,28 @@ func (parser *Parser) parseExpressionList(end token.TokenType) []ast.Expression
 	return expressions
 }
 
+func (parser *Parser) parseImportExpression() ast.Expression {
+	expression := &ast.ImportExpression{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.STRING) {
+		return nil
+	}
+
+	expression.Path = &ast.StringLiteral{Token: parser.currentToken, Value: parser.currentToken.Literal}
+	return expression
+}
+
+func (parser *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	expression := &ast.MemberExpression{Token: parser.currentToken, Object: object}
+
+	if !parser.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+
+	expression.Member = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+	return expression
+}
+
 func (parser *Parser) parseIndexExpression(col ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: parser.currentToken, Collection: col}
 
@@ -401,31 +942,72 @@ func (parser *Parser) parseIndexExpression(col ast.Expression) ast.Expression {
 	return exp
 }
 
-func (parser *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionParameters parses the parameter list into function: names,
+// each optionally followed by = and a default value, and finally an optional
+// ...rest parameter. Once a parameter has a default all later ones need one,
+// so that arguments always fill the leading parameters.
+func (parser *Parser) parseFunctionParameters(function *ast.FunctionLiteral) bool {
+	function.Parameters = []*ast.Identifier{}
 
 	if parser.peekTokenIs(token.RPAREN) {
 		parser.nextToken()
-		return identifiers
+		return true
 	}
 
-	parser.nextToken()
+	defaults := []ast.Expression{}
+	hasDefaults := false
+	for {
+		if function.Rest != nil {
+			parser.report(Diagnostic{
+				Position: parser.peekToken.Position,
+				Message:  "Rest parameter must be last.",
+				Found:    parser.peekToken,
+			})
+			return false
+		}
 
-	identifier := &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
-	identifiers = append(identifiers, identifier)
+		if parser.peekTokenIs(token.ELLIPSIS) {
+			parser.nextToken()
+			if !parser.expectPeek(token.IDENTIFIER) {
+				return false
+			}
+			function.Rest = &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+		} else {
+			if !parser.expectPeek(token.IDENTIFIER) {
+				return false
+			}
+			identifier := &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
+			function.Parameters = append(function.Parameters, identifier)
+
+			var value ast.Expression
+			if parser.peekTokenIs(token.ASSIGN) {
+				parser.nextToken()
+				parser.nextToken()
+				if value = parser.parseExpression(ASSIGN); value == nil {
+					return false
+				}
+				hasDefaults = true
+			} else if hasDefaults {
+				parser.report(Diagnostic{
+					Position: identifier.Token.Position,
+					Message:  fmt.Sprintf("Parameter %s needs a default value, as an earlier parameter has one.", identifier.Value),
+					Found:    identifier.Token,
+				})
+				return false
+			}
+			defaults = append(defaults, value)
+		}
 
-	for parser.peekTokenIs(token.COMMA) {
-		parser.nextToken()
+		if !parser.peekTokenIs(token.COMMA) {
+			break
+		}
 		parser.nextToken()
-		identifier := &ast.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal}
-		identifiers = append(identifiers, identifier)
 	}
 
-	if !parser.expectPeek(token.RPAREN) {
-		return nil
+	if hasDefaults {
+		function.Defaults = defaults
 	}
-
-	return identifiers
+	return parser.expectPeek(token.RPAREN)
 }
 
 func (parser *Parser) currentTokenIs(tokenType token.TokenType) bool {
@@ -460,16 +1042,49 @@ func (parser *Parser) currentPrecedence() int {
 	return LOWEST
 }
 
+// Errors returns the error diagnostics formatted as "position: message".
 func (parser *Parser) Errors() []string {
-	return parser.errors
+	errors := []string{}
+	for _, diagnostic := range parser.diagnostics {
+		if diagnostic.Severity == Error {
+			errors = append(errors, diagnostic.String())
+		}
+	}
+	return errors
+}
+
+// Diagnostics returns every error and warning, in the order they were found.
+func (parser *Parser) Diagnostics() []Diagnostic {
+	return parser.diagnostics
+}
+
+// report records a diagnostic. The first error puts the parser in panic
+// mode, in which further errors are consequences of the first and are
+// dropped until the parser has resynchronized.
+func (parser *Parser) report(diagnostic Diagnostic) {
+	if diagnostic.Severity == Error {
+		if parser.panicking {
+			return
+		}
+		parser.panicking = true
+		parser.offending = diagnostic.Found
+	}
+	parser.diagnostics = append(parser.diagnostics, diagnostic)
 }
 
-func (parser *Parser) peekError(expectedToken token.TokenType) {
-	message := fmt.Sprintf("Expected %s, got %s instead.", expectedToken, parser.peekToken.Type)
-	parser.errors = append(parser.errors, message)
+func (parser *Parser) peekError(expected ...token.TokenType) {
+	parser.report(Diagnostic{
+		Position: parser.peekToken.Position,
+		Message:  fmt.Sprintf("Expected %s, got %s instead.", expectedList(expected), parser.peekToken.Type),
+		Expected: expected,
+		Found:    parser.peekToken,
+	})
 }
 
 func (parser *Parser) noPrefixParseFunctionError(tokenType token.TokenType) {
-	message := fmt.Sprintf("No prefix parse function for %s found.", tokenType)
-	parser.errors = append(parser.errors, message)
+	parser.report(Diagnostic{
+		Position: parser.currentToken.Position,
+		Message:  fmt.Sprintf("No prefix parse function for %s found.", tokenType),
+		Found:    parser.currentToken,
+	})
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"interpreter/ast"
 	"interpreter/lexer"
+	"interpreter/token"
 	"testing"
 )
 
@@ -139,6 +140,50 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "123456789012345678901234567890;"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Big == nil || literal.Big.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.Big wrong. got=%v", literal.Big)
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e3;", 1000},
+		{"2.5e-1;", 0.25},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -199,6 +244,18 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 div 5;", 5, "div", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 		{"foobar + barfoo;", "foobar", "+", "barfoo"},
 		{"foobar - barfoo;", "foobar", "-", "barfoo"},
 		{"foobar * barfoo;", "foobar", "*", "barfoo"},
@@ -245,6 +302,46 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"-a * b",
 			"((-a) * b)",
 		},
+		{
+			"a + 1 <= b == c >= d * 2",
+			"(((a + 1) <= b) == (c >= (d * 2)))",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a | b ^ c & d",
+			"((a | b) ^ (c & d))",
+		},
+		{
+			"a + b % c << 2",
+			"(a + ((b % c) << 2))",
+		},
+		{
+			"a - b div c * d",
+			"(a - ((b div c) * d))",
+		},
+		{
+			"a & 1 == 0",
+			"((a & 1) == 0)",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"-2 ** 2",
+			"(-(2 ** 2))",
+		},
+		{
+			"2 ** -1 * a",
+			"((2 ** (-1)) * a)",
+		},
 		{
 			"!-a",
 			"(!(-a))",
@@ -349,6 +446,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a = b = c + 1",
+			"(a = (b = (c + 1)))",
+		},
+		{
+			"a[i + 1] += 2 * 3",
+			"((a[(i + 1)]) += (2 * 3))",
+		},
+		{
+			"s <>= t <> u",
+			"(s <>= (t <> u))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -509,6 +618,162 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestInvalidAssignmentTarget(t *testing.T) {
+	l := lexer.New("1 + 2 = 3;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "1:7: Cannot assign to (1 + 2)." {
+		t.Errorf("expected invalid assignment error, got=%q", errors)
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (x < 10) { x }", "while(x < 10) x"},
+		{"for (x in xs) { x; }", "for (x in xs) x"},
+		{"for (i, x in [1, 2]) { break; continue; }", "for (i, x in [1, 2]) break;continue;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestTryAndThrow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { risky() } catch (e) { e }", "try risky() catch (e) e"},
+		{"try { risky() } finally { cleanup() }", "try risky() finally cleanup()"},
+		{"let x = try { a } catch (err) { b } finally { c };", "let x = try a catch (err) b finally c;"},
+		{"throw error(\"bad\");", "throw error(bad);"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let lib = import "lib/math";`, `let lib = import "lib/math";`},
+		{`import { add, sub } from "math";`, `import { add, sub } from "math";`},
+		{`export let pi = 3;`, `export let pi = 3;`},
+		{`lib.add(1, lib.two)`, `(lib.add)(1, (lib.two))`},
+		{`a.b.c[0]`, `(((a.b).c)[0])`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestModuleErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn() { export let x = 1; };", "1:16: Export is only allowed at the top level."},
+		{`import { a } "lib";`, "1:14: Expected from, got lib instead."},
+		{`import { a, 1 } from "lib";`, "1:13: Expected IDENTIFIER, got INT instead."},
+		{"lib.1", "1:5: Expected IDENTIFIER, got INT instead."},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestForStatementParsing(t *testing.T) {
+	input := `for (key, value in {"a": 1}) { key }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Key, "key") || !testIdentifier(t, stmt.Value, "value") {
+		return
+	}
+
+	if _, ok := stmt.Iterable.(*ast.HashLiteral); !ok {
+		t.Errorf("stmt.Iterable is not ast.HashLiteral. got=%T", stmt.Iterable)
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("stmt.Body.Statements does not contain 1 statements. got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func TestLoopTrailingSemicolon(t *testing.T) {
+	input := `let i = 0; while (i < 3) { i += 1 }; for (x in [1]) { x }; i`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if diagnostics := p.Diagnostics(); len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics. got=%v", diagnostics)
+	}
+	if len(program.Statements) != 4 {
+		t.Fatalf("program.Statements does not contain 4 statements. got=%d", len(program.Statements))
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -678,6 +943,137 @@ func TestCallExpressionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionSignatureParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 2) {}", "fn(a, b = 2) "},
+		{"fn(a = 1, b = a * 2) {}", "fn(a = 1, b = (a * 2)) "},
+		{"fn(first, ...rest) {}", "fn(first, ...rest) "},
+		{"fn(...all) {}", "fn(...all) "},
+		{"fn(a, b = [], ...rest) {}", "fn(a, b = [], ...rest) "},
+		{"f(...xs)", "f(...xs)"},
+		{"f(1, ...xs, 2)", "f(1, ...xs, 2)"},
+		{"f(1, sep: x, end: 2 + 3)", "f(1, sep: x, end: (2 + 3))"},
+		{"f(a: b: 1)", ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if tt.expected == "" {
+			if len(p.Errors()) == 0 {
+				t.Errorf("%q: expected a parse error", tt.input)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestTailCallMarking(t *testing.T) {
+	input := `fn(x) {
+		a();
+		if (x) { return b(c()); }
+		while (x) { return d(); }
+		try { e() } catch (err) { 1 };
+		x && f();
+		let inner = fn() { g(); h() };
+		if (x) { i() } else { j() || k() }
+	}`
+	expected := map[string]bool{
+		"a": false, "b": true, "c": false, "d": false, "e": false, "f": false,
+		"g": false, "h": true, "i": true, "j": false, "k": true,
+	}
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	tail := map[string]bool{}
+	collectCalls(program, tail)
+	if len(tail) != len(expected) {
+		t.Fatalf("found %d calls, want %d: %v", len(tail), len(expected), tail)
+	}
+	for name, want := range expected {
+		if tail[name] != want {
+			t.Errorf("call to %s: Tail=%t, want %t", name, tail[name], want)
+		}
+	}
+}
+
+// collectCalls records whether each call in node, by the name of the
+// function called, is in tail position.
+func collectCalls(node ast.Node, tail map[string]bool) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, statement := range node.Statements {
+			collectCalls(statement, tail)
+		}
+	case *ast.BlockStatement:
+		if node == nil {
+			return
+		}
+		for _, statement := range node.Statements {
+			collectCalls(statement, tail)
+		}
+	case *ast.ExpressionStatement:
+		collectCalls(node.Expression, tail)
+	case *ast.ReturnStatement:
+		collectCalls(node.Value, tail)
+	case *ast.LetStatement:
+		collectCalls(node.Value, tail)
+	case *ast.WhileStatement:
+		collectCalls(node.Body, tail)
+	case *ast.TryExpression:
+		collectCalls(node.Block, tail)
+		collectCalls(node.Catch, tail)
+	case *ast.IfExpression:
+		collectCalls(node.Consequence, tail)
+		collectCalls(node.Alternative, tail)
+	case *ast.InfixExpression:
+		collectCalls(node.LeftOperand, tail)
+		collectCalls(node.RightOperand, tail)
+	case *ast.FunctionLiteral:
+		collectCalls(node.Body, tail)
+	case *ast.CallExpression:
+		tail[node.Function.String()] = node.Tail
+		for _, argument := range node.Arguments {
+			collectCalls(argument, tail)
+		}
+	}
+}
+
+func TestFunctionSignatureErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a = 1, b) {}", "1:11: Parameter b needs a default value, as an earlier parameter has one."},
+		{"fn(...rest, a) {}", "1:13: Rest parameter must be last."},
+		{"fn(...rest = 1) {}", "1:12: Expected ), got = instead."},
+		{"fn(1) {}", "1:4: Expected IDENTIFIER, got INT instead."},
+		{"f(a: 1, 2)", "1:9: Positional argument follows named argument."},
+		{"f(a: 1, ...xs)", "1:9: Positional argument follows named argument."},
+		{"f(a: 1, a: 2)", "1:9: Duplicate named argument a."},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hello world";`
 
@@ -989,3 +1385,151 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 	t.FailNow()
 }
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", "test.mk:1:7: Expected =, got INT instead."},
+		{"let x = 5;\nadd(1, 2;", "test.mk:2:9: Expected ), got ; instead."},
+		{"let x = 5;\n\n  let y = );", "test.mk:3:11: No prefix parse function for ) found."},
+		{"try { 1 } 2", "test.mk:1:11: Expected CATCH or FINALLY, got INT instead."},
+		{"try { 1 } catch { 2 }", "test.mk:1:17: Expected (, got { instead."},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewFile("test.mk", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestProgramComments(t *testing.T) {
+	input := `// add two numbers
+let add = fn(x, y) { x + y }; /* unterminated`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+
+	if len(program.Comments) != 2 {
+		t.Fatalf("program.Comments does not contain 2 comments. got=%d", len(program.Comments))
+	}
+
+	if program.Comments[0].Literal != "// add two numbers" {
+		t.Errorf("program.Comments[0] wrong. got=%q", program.Comments[0].Literal)
+	}
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "2:31: Unterminated block comment." {
+		t.Errorf("expected unterminated comment error, got=%q", errors)
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let = 5; let y = 10;", []string{"1:5: Expected IDENTIFIER, got = instead."}},
+		{"let x = 5 +; let y = ;\nlet z = 1;", []string{
+			"1:12: No prefix parse function for ; found.",
+			"1:22: No prefix parse function for ; found.",
+		}},
+		{"let x = 1 +\nlet y = );", []string{
+			"2:1: No prefix parse function for LET found.",
+			"2:9: No prefix parse function for ) found.",
+		}},
+		{"let f = fn() {\n  let = 1;\n  return );\n};\nlet ok = 2;", []string{
+			"2:7: Expected IDENTIFIER, got = instead.",
+			"3:10: No prefix parse function for ) found.",
+		}},
+		{"add(1, 2; let x 3", []string{
+			"1:9: Expected ), got ; instead.",
+			"1:17: Expected =, got INT instead.",
+		}},
+		{"if (x { 1 } let y = 2;", []string{"1:7: Expected ), got { instead."}},
+		{"let f = fn(a, { a }; let y = 2;", []string{"1:15: Expected IDENTIFIER, got { instead."}},
+		{"fn(a = 1, b) {}", []string{"1:11: Parameter b needs a default value, as an earlier parameter has one."}},
+		{"-) = 1", []string{"1:2: No prefix parse function for ) found."}},
+		{"(-) = 1", []string{"1:3: No prefix parse function for ) found."}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("%q: wrong number of errors. expected=%q, got=%q", tt.input, tt.expected, errors)
+			continue
+		}
+
+		for i, expected := range tt.expected {
+			if errors[i] != expected {
+				t.Errorf("%q: wrong error %d. expected=%q, got=%q", tt.input, i, expected, errors[i])
+			}
+		}
+	}
+}
+
+func TestRecoveryKeepsLaterStatements(t *testing.T) {
+	p := New(lexer.New("let = 1; let a = 2; let b = 3;"))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("expected one error. got=%q", p.Errors())
+	}
+
+	if program.String() != "let a = 2;let b = 3;" {
+		t.Errorf("wrong statements after recovery. got=%q", program.String())
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	p := New(lexer.NewFile("test.mk", "try { 1 } 2;;\n/* open"))
+	p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 3 {
+		t.Fatalf("expected 3 diagnostics. got=%v", diagnostics)
+	}
+
+	expected := diagnostics[0]
+	if expected.Severity != Error || expected.Position.String() != "test.mk:1:11" ||
+		expected.Found.Type != token.INT || expected.Found.Literal != "2" {
+		t.Errorf("wrong diagnostic. got=%+v", expected)
+	}
+	if len(expected.Expected) != 2 || expected.Expected[0] != token.CATCH || expected.Expected[1] != token.FINALLY {
+		t.Errorf("wrong expected set. got=%v", expected.Expected)
+	}
+
+	warning := diagnostics[1]
+	if warning.Severity != Warning || warning.String() != "test.mk:1:13: warning: Empty statement." {
+		t.Errorf("wrong warning. got=%q", warning.String())
+	}
+
+	lexical := diagnostics[2]
+	if lexical.Severity != Error || lexical.String() != "test.mk:2:1: Unterminated block comment." {
+		t.Errorf("wrong lexer diagnostic. got=%q", lexical.String())
+	}
+
+	if len(p.Errors()) != 2 {
+		t.Errorf("Errors should leave out warnings. got=%q", p.Errors())
+	}
+}
@@ -0,0 +1,52 @@
+package repl
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"io"
+	"reflect"
+	"strings"
+)
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// dumpNode writes node as an indented tree: one line per node with its type
+// and token, followed by the nodes it contains, labelled by field.
+func dumpNode(out io.Writer, label string, node ast.Node, depth int) {
+	value := reflect.ValueOf(node)
+	if !value.IsValid() || value.Kind() == reflect.Pointer && value.IsNil() {
+		return
+	}
+
+	indent := strings.Repeat("  ", depth)
+	name := reflect.Indirect(value).Type().Name()
+	fmt.Fprintf(out, "%s%s%s %q\n", indent, label, name, node.TokenLiteral())
+
+	// Hash literals list their pairs in source order.
+	if hash, ok := node.(*ast.HashLiteral); ok {
+		for _, key := range hash.Keys {
+			dumpNode(out, "Pairs key: ", key, depth+1)
+			dumpNode(out, "Pairs value: ", hash.Pairs[key], depth+1)
+		}
+		return
+	}
+
+	structValue := reflect.Indirect(value)
+	for i := 0; i < structValue.NumField(); i++ {
+		field := structValue.Type().Field(i)
+		dumpField(out, field.Name, structValue.Field(i), depth+1)
+	}
+}
+
+func dumpField(out io.Writer, name string, field reflect.Value, depth int) {
+	switch {
+	case field.Type().Implements(nodeType):
+		if !field.IsNil() {
+			dumpNode(out, name+": ", field.Interface().(ast.Node), depth)
+		}
+	case field.Kind() == reflect.Slice && field.Type().Elem().Implements(nodeType):
+		for i := 0; i < field.Len(); i++ {
+			dumpField(out, fmt.Sprintf("%s[%d]", name, i), field.Index(i), depth)
+		}
+	}
+}
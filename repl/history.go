@@ -0,0 +1,119 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MaxHistory is how many entries the history keeps.
+const MaxHistory = 1000
+
+// History remembers the inputs of earlier sessions. Each entry is stored
+// quoted on its own line, so that multi-line inputs survive a round trip.
+type History struct {
+	file    string
+	entries []string
+	lines   int // lines in file, which may hold more entries than are kept
+}
+
+// DefaultHistoryFile is where Start keeps its history, or "" if there is
+// no home directory.
+func DefaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".interpreter_history")
+}
+
+// LoadHistory reads the history kept in file. A missing or unreadable
+// file gives an empty history; with file "" the history is not persisted.
+func LoadHistory(file string) *History {
+	history := &History{file: file}
+	if file == "" {
+		return history
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return history
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		history.lines++
+		if entry, err := strconv.Unquote(scanner.Text()); err == nil {
+			history.entries = append(history.entries, entry)
+		}
+	}
+	f.Close()
+
+	history.trim()
+	if history.lines > MaxHistory {
+		history.save()
+	}
+
+	return history
+}
+
+// Add records an entry and appends it to the history file. Once the file
+// holds twice MaxHistory lines it is rewritten with just the kept entries.
+// Failing to write the file is not worth interrupting a session for, so it
+// is ignored.
+func (h *History) Add(entry string) {
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return
+	}
+
+	h.entries = append(h.entries, entry)
+	h.trim()
+
+	if h.file == "" {
+		return
+	}
+	if h.lines >= 2*MaxHistory {
+		h.save()
+		return
+	}
+
+	f, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err := f.WriteString(strconv.Quote(entry) + "\n"); err == nil {
+		h.lines++
+	}
+}
+
+func (h *History) Entries() []string {
+	return h.entries
+}
+
+// save replaces the history file with the kept entries. It writes a
+// temporary file first, so that a failed write leaves the old history.
+func (h *History) save() {
+	var buf strings.Builder
+	for _, entry := range h.entries {
+		buf.WriteString(strconv.Quote(entry) + "\n")
+	}
+
+	tmp := h.file + ".tmp"
+	if err := os.WriteFile(tmp, []byte(buf.String()), 0o600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, h.file); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	h.lines = len(h.entries)
+}
+
+func (h *History) trim() {
+	if len(h.entries) > MaxHistory {
+		h.entries = h.entries[len(h.entries)-MaxHistory:]
+	}
+}
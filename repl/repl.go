@@ -2,44 +2,207 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"interpreter/ast"
 	"interpreter/evaluator"
 	"interpreter/lexer"
 	"interpreter/object"
 	"interpreter/parser"
+	"interpreter/token"
 	"io"
+	"os"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// CONTINUATION_PROMPT asks for the rest of an input whose brackets are not
+// balanced yet.
+const CONTINUATION_PROMPT = ".. "
+
+const help = `:load <file>    run a file in the current environment
+:env            list the variables defined in the session
+:ast <input>    show the syntax tree of input
+:tokens <input> show the tokens of input
+:history        list earlier inputs
+:reset          forget every variable
+:quit           end the session
+`
+
+type session struct {
+	env     *object.Environment
+	out     io.Writer
+	history *History
+}
+
+// Start runs a session that keeps its history in DefaultHistoryFile.
 func Start(in io.Reader, out io.Writer) {
+	Run(in, out, LoadHistory(DefaultHistoryFile()))
+}
+
+// Run reads inputs from in until it ends or the user quits, and records
+// them in history. An input continues over several lines for as long as
+// its parentheses, brackets or braces are unbalanced.
+func Run(in io.Reader, out io.Writer, history *History) {
+	s := &session{env: object.NewEnvironment(), out: out, history: history}
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	var pending []string
 
 	for {
-		fmt.Print(PROMPT)
+		if len(pending) == 0 {
+			io.WriteString(out, PROMPT)
+		} else {
+			io.WriteString(out, CONTINUATION_PROMPT)
+		}
 
-		input := scanner.Scan()
-		if !input {
+		if !scanner.Scan() {
+			if len(pending) != 0 {
+				io.WriteString(out, "\n")
+				s.eval("", strings.Join(pending, "\n"))
+			}
 			return
 		}
 
 		line := scanner.Text()
-		lex := lexer.New(line)
-		par := parser.New(lex)
+		if len(pending) == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			history.Add(strings.TrimSpace(line))
+			if !s.command(strings.TrimSpace(line)) {
+				return
+			}
+			continue
+		}
 
-		program := par.ParseProgram()
-		if len(par.Errors()) != 0 {
-			printParserErrors(out, par.Errors())
+		pending = append(pending, line)
+		input := strings.Join(pending, "\n")
+		if needsMoreInput(input) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		pending = nil
+		if strings.TrimSpace(input) == "" {
+			continue
 		}
+
+		history.Add(input)
+		s.eval("", input)
+	}
+}
+
+// needsMoreInput reports whether input has unclosed brackets or an
+// unterminated block comment.
+func needsMoreInput(input string) bool {
+	lex := lexer.New(input)
+	depth := 0
+
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+	}
+
+	return depth > 0 || len(lex.Errors()) != 0
+}
+
+func (s *session) eval(filename, input string) {
+	program, ok := s.parse(filename, input)
+	if !ok {
+		return
+	}
+
+	// Each input gets a fresh budget, so one that hit a limit does not
+	// fail every input after it.
+	s.env.Meter().Reset(context.Background(), object.Limits{})
+	evaluated := evaluator.Eval(program, s.env)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		io.WriteString(s.out, errObj.Traceback())
+		io.WriteString(s.out, "\n")
+	} else if evaluated != nil {
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+}
+
+func (s *session) parse(filename, input string) (*ast.Program, bool) {
+	par := parser.New(lexer.NewFile(filename, input))
+	program := par.ParseProgram()
+	if len(par.Errors()) != 0 {
+		printParserErrors(s.out, par.Errors())
+		return nil, false
+	}
+	return program, true
+}
+
+// command runs a meta-command and reports whether the session goes on.
+func (s *session) command(line string) bool {
+	name, argument, _ := strings.Cut(line, " ")
+	argument = strings.TrimSpace(argument)
+
+	switch name {
+	case ":quit":
+		return false
+	case ":help":
+		io.WriteString(s.out, help)
+	case ":load":
+		s.load(argument)
+	case ":env":
+		s.printEnvironment()
+	case ":ast":
+		if program, ok := s.parse("", argument); ok {
+			for _, statement := range program.Statements {
+				dumpNode(s.out, "", statement, 0)
+			}
+		}
+	case ":tokens":
+		lex := lexer.New(argument)
+		for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+			fmt.Fprintf(s.out, "%s %s %q\n", tok.Position, tok.Type, tok.Literal)
+		}
+	case ":history":
+		for i, entry := range s.history.Entries() {
+			fmt.Fprintf(s.out, "%4d  %s\n", i+1, strings.ReplaceAll(entry, "\n", "\n      "))
+		}
+	case ":reset":
+		s.env = object.NewEnvironment()
+		io.WriteString(s.out, "environment reset\n")
+	default:
+		fmt.Fprintf(s.out, "unknown command %s, type :help for a list\n", name)
+	}
+
+	return true
+}
+
+func (s *session) load(filename string) {
+	if filename == "" {
+		io.WriteString(s.out, "usage: :load <file>\n")
+		return
+	}
+
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+
+	s.eval(filename, string(source))
+}
+
+func (s *session) printEnvironment() {
+	for _, name := range s.env.Names() {
+		value, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, summarize(value))
+	}
+}
+
+// summarize shows a function by its parameters rather than its whole body.
+func summarize(value object.Object) string {
+	if fn, ok := value.(*object.Function); ok {
+		return "fn(" + strings.Join(fn.ParameterList(), ", ") + ")"
 	}
+	return value.Inspect()
 }
 
 func printParserErrors(out io.Writer, errors []string) {
@@ -0,0 +1,151 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func runSession(t *testing.T, input string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	Run(strings.NewReader(input), &out, LoadHistory(""))
+	return out.String()
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := "let add = fn(a, b) {\n  a + b\n};\nadd(1,\n 2)\n"
+
+	expected := ">> .. .. >> .. 3\n>> "
+	if got := runSession(t, input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestBudgetPerInput(t *testing.T) {
+	input := "let f = fn() { 1 + f() };\nf()\n1 + 1\n"
+
+	got := runSession(t, input)
+	if !strings.Contains(got, "call depth limit exceeded") || !strings.HasSuffix(got, ">> 2\n>> ") {
+		t.Errorf("an input after a failed one should run. got=%q", got)
+	}
+}
+
+func TestNeedsMoreInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"fn(x) {", true},
+		{"[1, [2,", true},
+		{"foo(", true},
+		{"/* still", true},
+		{`"{"`, false},
+		{"1 }", false},
+	}
+
+	for _, tt := range tests {
+		if got := needsMoreInput(tt.input); got != tt.expected {
+			t.Errorf("needsMoreInput(%q) = %t, want %t", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	library := filepath.Join(dir, "lib.mk")
+	if err := os.WriteFile(library, []byte("let square = fn(x) { x * x };\nlet base = 4;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":load " + library + "\nsquare(base)\n", ">> >> 16\n>> "},
+		{"let x = 1;\nlet f = fn(a, b) { a };\n:env\n", ">> >> >> f = fn(a, b)\nx = 1\n>> "},
+		{"let x = 1;\n:reset\nx\n", ">> >> environment reset\n>> Error: 1:1: identifier not found: x\n>> "},
+		{":quit\n1\n", ">> "},
+		{":tokens let x = 5;\n", ">> 1:1 LET \"let\"\n1:5 IDENTIFIER \"x\"\n1:7 = \"=\"\n1:9 INT \"5\"\n1:10 ; \";\"\n>> "},
+		{":ast -a * 2\n", ">> ExpressionStatement \"-\"\n" +
+			"  Expression: InfixExpression \"*\"\n" +
+			"    LeftOperand: PrefixExpression \"-\"\n" +
+			"      Operand: Identifier \"a\"\n" +
+			"    RightOperand: IntegerLiteral \"2\"\n>> "},
+		{":ast let = 1\n", ">> \t1:5: Expected IDENTIFIER, got = instead.\n>> "},
+		{":load\n", ">> usage: :load <file>\n>> "},
+		{":frobnicate\n", ">> unknown command :frobnicate, type :help for a list\n>> "},
+	}
+
+	for _, tt := range tests {
+		if got := runSession(t, tt.input); got != tt.expected {
+			t.Errorf("wrong output for %q.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestHistoryPersists(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+
+	var out bytes.Buffer
+	Run(strings.NewReader("let f = fn() {\n  1\n};\n:env\n:env\n"), &out, LoadHistory(file))
+
+	history := LoadHistory(file)
+	expected := []string{"let f = fn() {\n  1\n};", ":env"}
+	if !reflect.DeepEqual(history.Entries(), expected) {
+		t.Errorf("wrong history. expected=%q, got=%q", expected, history.Entries())
+	}
+
+	out.Reset()
+	Run(strings.NewReader(":history\n"), &out, history)
+	if !strings.Contains(out.String(), "   1  let f = fn() {\n        1\n      };\n   2  :env\n") {
+		t.Errorf("wrong history listing. got=%q", out.String())
+	}
+}
+
+func TestHistoryFileIsTrimmed(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+
+	var lines strings.Builder
+	for i := range MaxHistory + 10 {
+		lines.WriteString(strconv.Quote(strconv.Itoa(i)) + "\n")
+	}
+	if err := os.WriteFile(file, []byte(lines.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	history := LoadHistory(file)
+	if count := countLines(t, file); count != MaxHistory {
+		t.Errorf("loading should trim the file to %d lines. got=%d", MaxHistory, count)
+	}
+	if first := history.Entries()[0]; first != "10" {
+		t.Errorf("wrong first entry. got=%q", first)
+	}
+
+	for i := range 2 * MaxHistory {
+		history.Add("entry " + strconv.Itoa(i))
+		if count := countLines(t, file); count > 2*MaxHistory {
+			t.Fatalf("history file grew to %d lines", count)
+		}
+	}
+
+	reloaded := LoadHistory(file)
+	if !reflect.DeepEqual(reloaded.Entries(), history.Entries()) {
+		t.Errorf("reloaded history differs from the session's")
+	}
+}
+
+func countLines(t *testing.T, file string) int {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
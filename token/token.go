@@ -1,20 +1,52 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type     TokenType
+	Literal  string
+	Position Position
+}
+
+// Position is a location in source code. Line and Column start at 1,
+// Offset is the byte offset into the input starting at 0.
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+	Offset   int
+}
+
+// IsValid reports whether the position has been set.
+func (position Position) IsValid() bool {
+	return position.Line > 0
+}
+
+func (position Position) String() string {
+	if !position.IsValid() {
+		if position.Filename != "" {
+			return position.Filename
+		}
+		return "-"
+	}
+	if position.Filename == "" {
+		return fmt.Sprintf("%d:%d", position.Line, position.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", position.Filename, position.Line, position.Column)
 }
 
 const (
 	// Special
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT"
 
 	// Identifiers and literals
 	IDENTIFIER = "IDENTIFIER"
 	INT        = "INT"
+	FLOAT      = "FLOAT"
 	STRING     = "STRING"
 
 	// Operators
@@ -26,12 +58,32 @@ const (
 	SLASH    = "/"
 	LT       = "<"
 	GT       = ">"
+	LT_EQ    = "<="
+	GT_EQ    = ">="
 	EQ       = "=="
 	NOT_EQ   = "!="
 	LTGT     = "<>"
+	PERCENT  = "%"
+	POWER    = "**"
+	AND      = "&&"
+	OR       = "||"
+
+	AMPERSAND   = "&"
+	PIPE        = "|"
+	CARET       = "^"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	LTGT_ASSIGN     = "<>="
 
 	// Delimiters
 	COMMA     = ","
+	DOT       = "."
+	ELLIPSIS  = "..."
 	COLON     = ":"
 	SEMICOLON = ";"
 	LPAREN    = "("
@@ -49,16 +101,40 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	DIV      = "DIV"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"div":      DIV,
 }
 
 func LookupIdentifier(identifier string) TokenType {
@@ -0,0 +1,65 @@
+package vm
+
+import (
+	"interpreter/evaluator"
+	"interpreter/object"
+)
+
+const ITERATOR_OBJ = "ITERATOR"
+
+// iterator is the state of a for-in loop. It lives on the stack below the
+// loop body and is never visible to programs.
+type iterator struct {
+	keys     []object.Object
+	values   []object.Object
+	position int
+	isHash   bool
+}
+
+func (it *iterator) Type() object.ObjectType { return ITERATOR_OBJ }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+func newIterator(iterable object.Object) (*iterator, *object.Error) {
+	it := &iterator{}
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		for i, element := range iterable.Elements {
+			it.keys = append(it.keys, &object.Integer{Value: int64(i)})
+			it.values = append(it.values, element)
+		}
+	case *object.String:
+		for i, char := range []rune(iterable.Value) {
+			it.keys = append(it.keys, &object.Integer{Value: int64(i)})
+			it.values = append(it.values, &object.String{Value: string(char)})
+		}
+	case *object.Hash:
+		it.isHash = true
+		for _, pair := range iterable.Ordered() {
+			it.keys = append(it.keys, pair.Key)
+			it.values = append(it.values, pair.Value)
+		}
+	default:
+		return nil, evaluator.NewError("cannot iterate over %s", iterable.Type())
+	}
+
+	return it, nil
+}
+
+// next returns the next key and value. For a single loop variable over a
+// hash, the value is the [key, value] pair, as in the evaluator.
+func (it *iterator) next(single bool) (object.Object, object.Object, bool) {
+	if it.position >= len(it.values) {
+		return nil, nil, false
+	}
+
+	key := it.keys[it.position]
+	value := it.values[it.position]
+	it.position += 1
+
+	if single && it.isHash {
+		value = &object.Array{Elements: []object.Object{key, value}}
+	}
+
+	return key, value, true
+}
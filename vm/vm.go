@@ -0,0 +1,591 @@
+package vm
+
+import (
+	"interpreter/code"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/object"
+)
+
+// StackSize leaves room for 16 slots of locals and temporaries in each of
+// MaxFrames frames, so that ordinary recursion reaches MaxFrames before it
+// runs out of stack.
+const StackSize = MaxFrames * 16
+const GlobalsSize = 65536
+const MaxFrames = 1024
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // Always points to the next free slot. Top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	// Upvalues that still point into the stack, keyed by stack slot.
+	openUpvalues map[int]*object.Upvalue
+
+	lastPopped object.Object
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobalsStore returns a VM that reads and writes globals in the
+// given store, so that the caller can define globals, such as a script's
+// args, before the program runs and read them afterwards.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		SourceMap:    bytecode.SourceMap,
+		NumLocals:    bytecode.NumLocals,
+		LocalNames:   bytecode.LocalNames,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants:    bytecode.Constants,
+		globals:      globals,
+		globalNames:  bytecode.GlobalNames,
+		stack:        make([]object.Object, StackSize),
+		sp:           bytecode.NumLocals,
+		frames:       frames,
+		framesIndex:  1,
+		openUpvalues: map[int]*object.Upvalue{},
+	}
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// Run executes the program and returns its result the way evaluator.Eval
+// does: the value of the last expression statement, the value of a top-level
+// return, or the *object.Error that stopped the program.
+func (vm *VM) Run() object.Object {
+	return vm.run(0)
+}
+
+// run executes instructions until the program ends or, if stop is not 0,
+// until the frame at index stop returns. Builtins that call back into
+// script functions start such a nested run; an error unwinds its frames
+// before it is returned.
+func (vm *VM) run(stop int) object.Object {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for {
+		frame := vm.currentFrame()
+		frame.ip++
+
+		ip = frame.ip
+		ins = frame.Instructions()
+
+		if ip >= len(ins) {
+			return vm.lastPopped
+		}
+
+		op = code.Opcode(ins[ip])
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+
+		case code.OpDup:
+			err = vm.push(vm.stack[vm.sp-1])
+
+		case code.OpDup2:
+			err = vm.push(vm.stack[vm.sp-2])
+			if err == nil {
+				err = vm.push(vm.stack[vm.sp-2])
+			}
+
+		case code.OpTrue:
+			err = vm.push(evaluator.TRUE)
+
+		case code.OpFalse:
+			err = vm.push(evaluator.FALSE)
+
+		case code.OpNull:
+			err = vm.push(evaluator.NULL)
+
+		case code.OpInfix:
+			operator := code.InfixOperators[code.ReadUint8(ins[ip+1:])]
+			frame.ip += 1
+
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.EvalInfix(operator, left, right))
+
+		case code.OpPrefix:
+			operator := code.PrefixOperators[code.ReadUint8(ins[ip+1:])]
+			frame.ip += 1
+
+			err = vm.pushResult(evaluator.EvalPrefix(operator, vm.pop()))
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				frame.ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			if vm.globals[globalIndex] == nil {
+				err = evaluator.NewError("cannot assign to undefined variable %s", vm.globalNames[globalIndex])
+			} else {
+				vm.globals[globalIndex] = vm.stack[vm.sp-1]
+			}
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			value := vm.globals[globalIndex]
+			if value == nil {
+				err = evaluator.NewError("identifier not found: %s", vm.globalNames[globalIndex])
+			} else {
+				err = vm.push(value)
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpAssignLocal:
+			localIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			slot := frame.basePointer + int(localIndex)
+			if vm.stack[slot] == nil {
+				err = evaluator.NewError("cannot assign to undefined variable %s", frame.cl.Fn.LocalNames[localIndex])
+			} else {
+				vm.stack[slot] = vm.stack[vm.sp-1]
+			}
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			value := vm.stack[frame.basePointer+int(localIndex)]
+			if value == nil {
+				err = evaluator.NewError("identifier not found: %s", frame.cl.Fn.LocalNames[localIndex])
+			} else {
+				err = vm.push(value)
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			value := *frame.cl.Free[freeIndex].Location
+			if value == nil {
+				err = evaluator.NewError("identifier not found: %s", frame.cl.Fn.Captures[freeIndex].Name)
+			} else {
+				err = vm.push(value)
+			}
+
+		case code.OpAssignFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			*frame.cl.Free[freeIndex].Location = vm.stack[vm.sp-1]
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			builtin, _ := evaluator.LookupBuiltin(evaluator.BuiltinNames()[builtinIndex])
+			err = vm.push(builtin)
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(&object.Array{Elements: elements})
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			hash, hashErr := vm.buildHash(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+			if hashErr != nil {
+				err = hashErr
+			} else {
+				err = vm.push(hash)
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			collection := vm.pop()
+			err = vm.pushResult(evaluator.EvalIndex(collection, index))
+
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			collection := vm.pop()
+			err = vm.pushResult(evaluator.EvalIndexAssignment(collection, index, value))
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			err = vm.pushClosure(int(constIndex))
+
+		case code.OpCloseUpvalues:
+			slot := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			vm.closeUpvalues(frame.basePointer + slot)
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			err = vm.executeCall(int(numArgs))
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			if vm.framesIndex == 1 {
+				return returnValue
+			}
+
+			frame := vm.popFrame()
+			vm.closeUpvalues(frame.basePointer)
+			vm.sp = frame.basePointer - 1
+
+			if vm.framesIndex == stop {
+				return returnValue
+			}
+			err = vm.push(returnValue)
+
+		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				return evaluator.NULL
+			}
+
+			frame := vm.popFrame()
+			vm.closeUpvalues(frame.basePointer)
+			vm.sp = frame.basePointer - 1
+
+			if vm.framesIndex == stop {
+				return evaluator.NULL
+			}
+			err = vm.push(evaluator.NULL)
+
+		case code.OpIterInit:
+			iterable := vm.pop()
+			iter, iterErr := newIterator(iterable)
+			if iterErr != nil {
+				err = iterErr
+			} else {
+				err = vm.push(iter)
+			}
+
+		case code.OpIterNext:
+			exit := int(code.ReadUint16(ins[ip+1:]))
+			numVariables := int(code.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+
+			iter := vm.stack[vm.sp-1].(*iterator)
+			key, value, ok := iter.next(numVariables == 1)
+			if !ok {
+				frame.ip = exit - 1
+			} else if numVariables == 2 {
+				err = vm.push(key)
+				if err == nil {
+					err = vm.push(value)
+				}
+			} else {
+				err = vm.push(value)
+			}
+
+		case code.OpFail:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			message := vm.constants[constIndex].(*object.String).Value
+			err = evaluator.NewError("%s", message)
+
+		default:
+			def, _ := code.Lookup(byte(op))
+			err = evaluator.NewError("unknown opcode %v", def)
+		}
+
+		if err != nil {
+			err = vm.locate(err, stop)
+			if stop > 0 {
+				vm.unwind(stop)
+			}
+			return err
+		}
+	}
+}
+
+// locate gives a runtime error the source position of the instruction that
+// raised it, unless it already has one, and records the active calls in its
+// stack trace. Calls made before the frame at index stop are left to the
+// enclosing run.
+func (vm *VM) locate(err *object.Error, stop int) *object.Error {
+	frame := vm.currentFrame()
+	if !err.Position.IsValid() {
+		err.Position = frame.cl.Fn.SourceMap.Lookup(frame.ip)
+	}
+
+	for i := range err.Stack {
+		if !err.Stack[i].Position.IsValid() {
+			err.Stack[i].Position = frame.cl.Fn.SourceMap.Lookup(frame.ip)
+		}
+	}
+
+	for i := vm.framesIndex - 1; i > 0 && i >= stop; i-- {
+		caller := vm.frames[i-1]
+		err.Stack = append(err.Stack, object.StackFrame{
+			Function: vm.frames[i].cl.Fn.Name,
+			Position: caller.cl.Fn.SourceMap.Lookup(caller.ip),
+		})
+	}
+	return err
+}
+
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.BuiltIn:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		vm.sp = vm.sp - numArgs - 1
+		return evaluator.NewError("Object is not a function, got %s instead.", callee.Type())
+	}
+}
+
+// callClosure clears the local slots beyond the arguments so that a local
+// read before its `let` is reported.
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) *object.Error {
+	fn := cl.Fn
+	if numArgs != fn.NumParameters {
+		return failedCall(fn, evaluator.ArityError(fn.Name, fn.NumParameters, fn.NumParameters, numArgs))
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return failedCall(fn, stackOverflow())
+	}
+
+	basePointer := vm.sp - fn.NumParameters
+
+	if basePointer+fn.NumLocals >= StackSize {
+		return failedCall(fn, stackOverflow())
+	}
+
+	for i := basePointer + fn.NumParameters; i < basePointer+fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+
+	vm.pushFrame(NewFrame(cl, basePointer))
+	vm.sp = basePointer + fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.BuiltIn, numArgs int) *object.Error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result := builtin.Call(vm.call, nil, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		result = evaluator.NULL
+	}
+	return vm.pushResult(result)
+}
+
+// call is the Caller given to higher-order builtins. It runs a closure to
+// completion on top of the current frames.
+func (vm *VM) call(fn object.Object, args ...object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Closure:
+		sp := vm.sp
+		if sp+1+len(args) >= StackSize {
+			return failedCall(fn.Fn, stackOverflow())
+		}
+		vm.stack[vm.sp] = fn
+		copy(vm.stack[vm.sp+1:], args)
+		vm.sp += 1 + len(args)
+
+		if err := vm.callClosure(fn, len(args)); err != nil {
+			vm.sp = sp
+			return err
+		}
+
+		lastPopped := vm.lastPopped
+		result := vm.run(vm.framesIndex - 1)
+		vm.lastPopped = lastPopped
+		return result
+	case *object.BuiltIn:
+		result := fn.Call(vm.call, nil, args...)
+		if result == nil {
+			return evaluator.NULL
+		}
+		return result
+	default:
+		return evaluator.NewError("Object is not a function, got %s instead.", fn.Type())
+	}
+}
+
+// unwind discards the frame at index stop and everything above it, with
+// the callee and arguments below it, after a nested run failed.
+func (vm *VM) unwind(stop int) {
+	frame := vm.frames[stop]
+	vm.closeUpvalues(frame.basePointer)
+	vm.framesIndex = stop
+	vm.sp = frame.basePointer - 1
+}
+
+func (vm *VM) pushClosure(constIndex int) *object.Error {
+	fn, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return evaluator.NewError("not a function: %+v", vm.constants[constIndex])
+	}
+
+	frame := vm.currentFrame()
+	free := make([]*object.Upvalue, len(fn.Captures))
+	for i, capture := range fn.Captures {
+		if capture.Local {
+			free[i] = vm.captureUpvalue(frame.basePointer + capture.Index)
+		} else {
+			free[i] = frame.cl.Free[capture.Index]
+		}
+	}
+
+	return vm.push(&object.Closure{Fn: fn, Free: free})
+}
+
+func (vm *VM) captureUpvalue(slot int) *object.Upvalue {
+	if upvalue, ok := vm.openUpvalues[slot]; ok {
+		return upvalue
+	}
+
+	upvalue := &object.Upvalue{Location: &vm.stack[slot]}
+	vm.openUpvalues[slot] = upvalue
+	return upvalue
+}
+
+// closeUpvalues closes every open upvalue at or above slot, because those
+// slots are about to be popped or reused.
+func (vm *VM) closeUpvalues(slot int) {
+	for s, upvalue := range vm.openUpvalues {
+		if s >= slot {
+			upvalue.Close()
+			delete(vm.openUpvalues, s)
+		}
+	}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, *object.Error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, evaluator.NewError("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp >= StackSize {
+		return stackOverflow()
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// pushResult pushes the result of an evaluator operation, or returns it if
+// it is an error.
+func (vm *VM) pushResult(o object.Object) *object.Error {
+	if err, ok := o.(*object.Error); ok {
+		return err
+	}
+	return vm.push(o)
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// failedCall records a call that failed before its frame was pushed, the
+// way the evaluator records the call; locate fills in the position.
+func failedCall(fn *object.CompiledFunction, err *object.Error) *object.Error {
+	err.Stack = append(err.Stack, object.StackFrame{Function: fn.Name})
+	return err
+}
+
+// stackOverflow is reported when a call would exceed the frame or value
+// stack, which in practice means runaway recursion.
+func stackOverflow() *object.Error {
+	return &object.Error{Kind: object.CallDepthExceeded, Message: "stack overflow"}
+}
@@ -0,0 +1,246 @@
+package vm
+
+import (
+	"go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// knownDifferences lists evaluator test programs on which the VM is expected
+// to give a different result, with the reason.
+var knownDifferences = map[string]string{
+	// The evaluator only notices the stray continue when the signal leaves
+	// the function, so it reports the call; the compiler reports the
+	// statement itself.
+	"for (x in [1]) { let f = fn() { continue; }; f(); }": "error position",
+	// The evaluator keeps only the innermost tail calls in the stack trace.
+	"let down = fn(n) { if (n == 0) { missing } else { down(n - 1) } };\ndown(1000);": "elided tail calls",
+}
+
+// skippedTests are evaluator tests whose programs only terminate under
+// evaluator settings that the VM does not have, or recurse deeper than the
+// VM's MaxFrames because the evaluator runs tail calls in constant space.
+var skippedTests = map[string]bool{
+	"TestExecutionLimits": true,
+	"TestTailCalls":       true,
+}
+
+func run(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	result, err := compileAndRun(input)
+	if err != nil {
+		t.Fatalf("compiler error for %q: %s", input, err)
+	}
+	return result
+}
+
+func compileAndRun(input string) (object.Object, error) {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	return New(comp.Bytecode()).Run(), nil
+}
+
+func evaluate(input string) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	return evaluator.Eval(program, object.NewEnvironment())
+}
+
+// TestEvaluatorParity runs every program from the evaluator's tests through
+// the VM and checks that both engines agree on the result. Programs that use
+// constructs the compiler does not support yet are skipped.
+func TestEvaluatorParity(t *testing.T) {
+	inputs := evaluatorTestInputs(t)
+	if len(inputs) < 100 {
+		t.Fatalf("found only %d inputs in the evaluator tests", len(inputs))
+	}
+
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input))
+		program.ParseProgram()
+		if len(program.Errors()) != 0 || knownDifferences[input] != "" {
+			continue
+		}
+
+		actual, err := compileAndRun(input)
+		if err != nil {
+			if !strings.Contains(err.Error(), "cannot compile") {
+				t.Errorf("compiler error for %q: %s", input, err)
+			}
+			continue
+		}
+		expected := evaluate(input)
+
+		if !sameResult(expected, actual, map[*object.Array]bool{}) {
+			t.Errorf("engines disagree on %q.\nevaluator=%s\nvm=%s", input, describe(expected), describe(actual))
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`let newCounter = fn() { let n = 0; fn() { n += 1; n } }; let c = newCounter(); c(); c(); c()`, 3},
+		{`let f = []; for (i in [1, 2, 3]) { f = f <> [fn() { i }] }; f[2]() * 100 + f[1]() * 10 + f[0]()`, 321},
+		{`let x = 1; let setX = fn(v) { x = v }; setX(5); x`, 5},
+		{`let outer = fn() { let a = 1; let set = fn() { a = 4 }; set(); a }; outer()`, 4},
+		{`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)`, 610},
+		{`let later = fn() { value * 2 }; let value = 21; later()`, 42},
+	}
+
+	for _, tt := range tests {
+		result := run(t, tt.input)
+		integer, ok := result.(*object.Integer)
+		if !ok || integer.Value != tt.expected {
+			t.Errorf("wrong result for %q. want=%d, got=%s", tt.input, tt.expected, describe(result))
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { 1 } catch (e) { 2 }`, "1:1: cannot compile try expressions"},
+		{`throw "bad"`, "1:1: cannot compile throw statements"},
+		{`let m = import "lib"; m.x`, "1:9: cannot compile modules"},
+		{`let f = fn(a) { a }; f(...[1])`, "1:24: cannot compile spread arguments"},
+		{`let f = fn(a) { a }; f(a: 1)`, "1:24: cannot compile named arguments"},
+		{`fn(a = 1) { a }`, "1:1: cannot compile default or rest parameters"},
+	}
+
+	for _, tt := range tests {
+		_, err := compileAndRun(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestDeepRecursion(t *testing.T) {
+	result := run(t, "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(1000)")
+
+	integer, ok := result.(*object.Integer)
+	if !ok || integer.Value != 1000 {
+		t.Errorf("wrong result. want=1000, got=%s", describe(result))
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	result := run(t, "let f = fn(n) { f(n + 1) }; f(0)")
+
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("expected an error. got=%s", describe(result))
+	}
+	if errObj.Message != "stack overflow" || errObj.Kind != object.CallDepthExceeded {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+// evaluatorTestInputs collects the source programs used by the evaluator's
+// table tests: the first string of every composite literal, plus any string
+// assigned to a variable called input.
+func evaluatorTestInputs(t *testing.T) []string {
+	fset := gotoken.NewFileSet()
+	file, err := goparser.ParseFile(fset, "../evaluator/evaluator_test.go", nil, 0)
+	if err != nil {
+		t.Fatalf("could not read evaluator tests: %s", err)
+	}
+
+	seen := map[string]bool{}
+	var inputs []string
+	add := func(expr ast.Expr) {
+		lit, ok := expr.(*ast.BasicLit)
+		if !ok || lit.Kind != gotoken.STRING {
+			return
+		}
+		value, err := strconv.Unquote(lit.Value)
+		if err != nil || seen[value] {
+			return
+		}
+		seen[value] = true
+		inputs = append(inputs, value)
+	}
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FuncDecl:
+			return !skippedTests[node.Name.Name]
+		case *ast.CompositeLit:
+			if len(node.Elts) > 0 {
+				add(node.Elts[0])
+			}
+		case *ast.AssignStmt:
+			if ident, ok := node.Lhs[0].(*ast.Ident); ok && ident.Name == "input" {
+				add(node.Rhs[0])
+			}
+		}
+		return true
+	})
+
+	return inputs
+}
+
+// sameResult reports whether the engines produced equivalent values. visited
+// holds the arrays already being compared, so that an array containing itself
+// does not recurse forever.
+func sameResult(expected, actual object.Object, visited map[*object.Array]bool) bool {
+	if expected == nil || actual == nil {
+		// A program ending in a let statement has no value in the evaluator;
+		// the VM reports whatever was computed last.
+		return expected == nil
+	}
+
+	if expected.Type() != actual.Type() {
+		return false
+	}
+
+	switch expected := expected.(type) {
+	case *object.Function:
+		return true
+	case *object.Error:
+		return expected.Traceback() == actual.(*object.Error).Traceback()
+	case *object.Array:
+		if visited[expected] {
+			return expected.Inspect() == actual.Inspect()
+		}
+		visited[expected] = true
+		defer delete(visited, expected)
+
+		elements := actual.(*object.Array).Elements
+		if len(elements) != len(expected.Elements) {
+			return false
+		}
+		for i, element := range expected.Elements {
+			if !sameResult(element, elements[i], visited) {
+				return false
+			}
+		}
+		return true
+	default:
+		return expected.Inspect() == actual.Inspect()
+	}
+}
+
+func describe(obj object.Object) string {
+	if obj == nil {
+		return "nil"
+	}
+	return string(obj.Type()) + " " + obj.Inspect()
+}